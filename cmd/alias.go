@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/spf13/cobra"
+)
+
+var (
+	functionFlag string
+)
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage your Lambda aliases",
+	Long:  "List the aliases of the functions in your recipe, or point an alias at a published version.",
+	ValidArgs: []string{
+		"list",
+		"set",
+	},
+	Args: cobra.OnlyValidArgs,
+}
+
+var aliasListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the aliases of your functions",
+	Long:    "List the aliases of every function in the recipe, or of the function given with --function, with the versions they point at.",
+	Example: "chefcli alias list\nchefcli alias list --stage prod",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		functions := aliasFunctions(LoadRecipe())
+		svc := lambda.New(newSession(), endpointConfig("lambda"))
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FUNCTION\tALIAS\tVERSION\tDESCRIPTION")
+		for _, fn := range functions {
+			err := svc.ListAliasesPages(&lambda.ListAliasesInput{
+				FunctionName: aws.String(fn.Function),
+			}, func(page *lambda.ListAliasesOutput, lastPage bool) bool {
+				for _, a := range page.Aliases {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", fn.Function, aws.StringValue(a.Name), aws.StringValue(a.FunctionVersion), aws.StringValue(a.Description))
+				}
+				return true
+			})
+			if CheckAWSError(err) {
+				os.Exit(1)
+			}
+		}
+		w.Flush()
+	},
+}
+
+var aliasSetCmd = &cobra.Command{
+	Use:     "set <alias> <version>",
+	Short:   "Point an alias at a version",
+	Long:    "Point an alias of the recipe function at a published version, creating the alias when it does not exist. Use --function when the recipe lists several functions.",
+	Example: "chefcli alias set prod 7\nchefcli alias set prod 7 --function orders",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		alias, version := args[0], args[1]
+		if _, err := strconv.Atoi(version); err != nil {
+			fmt.Println("The version must be the number of a published version, got " + version + ".")
+			os.Exit(1)
+		}
+
+		functions := aliasFunctions(LoadRecipe())
+		if len(functions) > 1 {
+			fmt.Println("The recipe lists several functions. Pick one with --function.")
+			os.Exit(1)
+		}
+		svc := lambda.New(newSession(), endpointConfig("lambda"))
+		if err := pointAlias(os.Stdout, svc, functions[0].Function, alias, version); err != nil {
+			if CheckAWSError(err) {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// Function to get the recipe functions an alias command works on.
+func aliasFunctions(cb *recipe.Cookbook) []*recipe.Cookbook {
+	if functionFlag == "" {
+		return cb.All()
+	}
+	fn, err := cb.Lookup(functionFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return []*recipe.Cookbook{fn}
+}
+
+// Function to point an alias at a version, creating the alias when it does
+// not exist yet.
+func pointAlias(out io.Writer, svc *lambda.Lambda, function, alias, version string) error {
+	current, err := svc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String(function),
+		Name:         aws.String(alias),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		_, err = svc.CreateAlias(&lambda.CreateAliasInput{
+			FunctionName:    aws.String(function),
+			Name:            aws.String(alias),
+			FunctionVersion: aws.String(version),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created alias %s of %s pointing at version %s.\n", alias, function, version)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// moving the alias also ends any canary routing
+	_, err = svc.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    aws.String(function),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		RoutingConfig: &lambda.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]*float64{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if previous := aws.StringValue(current.FunctionVersion); previous != version {
+		fmt.Fprintf(out, "Moved alias %s of %s from version %s to version %s.\n", alias, function, previous, version)
+	} else {
+		fmt.Fprintf(out, "Alias %s of %s sends all traffic to version %s.\n", alias, function, version)
+	}
+	return nil
+}
+
+// Function to publish a version of a function, described with the git
+// commit of its folder, and move the recipe alias to it, gradually with a
+// canary plan.
+func publishVersion(out io.Writer, svc *lambda.Lambda, dir string, cb *recipe.Cookbook, plan *canaryPlan) (string, error) {
+	description := "Published by ChefCLI"
+	if commit := gitCommit(dir); commit != "" {
+		description += " from commit " + commit
+	}
+	version, err := svc.PublishVersion(&lambda.PublishVersionInput{
+		FunctionName: aws.String(cb.Function),
+		Description:  aws.String(description),
+	})
+	if err != nil {
+		return "", err
+	}
+	number := aws.StringValue(version.Version)
+	fmt.Fprintf(out, "Published version %s of %s.\n", number, cb.Function)
+
+	if plan != nil {
+		if err := canaryDeploy(out, svc, cb, number, plan); err != nil {
+			return "", err
+		}
+	} else if cb.Alias != "" {
+		if err := pointAlias(out, svc, cb.Function, cb.Alias, number); err != nil {
+			return "", err
+		}
+	}
+	return number, nil
+}
+
+// Function to get the git commit of a folder, marked dirty when there are
+// uncommitted changes. It is empty outside of a git repository.
+func gitCommit(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	sha, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(sha))
+	if status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); err == nil && len(status) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+func init() {
+	aliasCmd.PersistentFlags().StringVar(&recipeFlag, "recipe", "", "path to the recipe file (defaults to recipe.yml or recipe.yaml).")
+	aliasCmd.PersistentFlags().StringVar(&stageFlag, "stage", "", "recipe stage to overlay on the base recipe, e.g. prod.")
+	aliasCmd.PersistentFlags().StringVar(&functionFlag, "function", "", "only work on the named function from the recipe.")
+
+	aliasCmd.AddCommand(aliasListCmd)
+	aliasCmd.AddCommand(aliasSetCmd)
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveTime is the modification time written for every archive entry, so
+// that identical sources always produce identical archives.
+var archiveTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Archive collects the files of a Lambda ZIP archive. Entries are written
+// sorted by name with fixed timestamps, keeping the executable bit of each
+// file, so two builds of the same code produce the same bytes.
+type Archive struct {
+	files map[string]string
+}
+
+// NewArchive returns an empty Archive.
+func NewArchive() *Archive {
+	return &Archive{files: map[string]string{}}
+}
+
+// AddFile adds the file at path as name inside the archive.
+func (a *Archive) AddFile(path, name string) {
+	a.files[strings.TrimPrefix(filepath.ToSlash(name), "/")] = path
+}
+
+// AddDir adds every file below dir, placed under prefix inside the archive.
+func (a *Archive) AddDir(dir, prefix string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		a.AddFile(p, path.Join(prefix, filepath.ToSlash(rel)))
+		return nil
+	})
+}
+
+// AddPath adds the file or folder rel, found below root, keeping its path
+// relative to root inside the archive. Folders for which skip returns true
+// are left out along with their contents, as are skipped files.
+func (a *Archive) AddPath(root, rel string, skip func(rel string, isDir bool) bool) error {
+	start := filepath.Join(root, rel)
+	if _, err := os.Stat(start); err != nil {
+		return err
+	}
+	return filepath.Walk(start, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%s is outside of %s", p, root)
+		}
+		if skip != nil && name != "." && skip(name, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			a.AddFile(p, name)
+		}
+		return nil
+	})
+}
+
+// Source returns the path of the file added as name, if any.
+func (a *Archive) Source(name string) (string, bool) {
+	src, ok := a.files[name]
+	return src, ok
+}
+
+// Trim removes the entries the matcher ignores and returns how many files
+// and bytes were left out.
+func (a *Archive) Trim(m *ignoreMatcher) (int, int64, error) {
+	files, size := 0, int64(0)
+	for name, src := range a.files {
+		if !m.Ignored(name) {
+			continue
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return 0, 0, err
+		}
+		delete(a.files, name)
+		files++
+		size += info.Size()
+	}
+	return files, size, nil
+}
+
+// Names returns the sorted entry names of the archive.
+func (a *Archive) Names() []string {
+	names := make([]string, 0, len(a.files))
+	for name := range a.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write writes the archive to dest and returns its SHA-256 in the base64
+// form Lambda reports as CodeSha256.
+func (a *Archive) Write(dest string) (string, error) {
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	w := zip.NewWriter(io.MultiWriter(out, hash))
+	for _, name := range a.Names() {
+		if err := a.writeEntry(w, name, a.files[name]); err != nil {
+			return "", fmt.Errorf("adding %s: %w", a.files[name], err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Function to compute the SHA-256 of archive contents in the base64 form
+// Lambda reports as CodeSha256.
+func codeSha256(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (a *Archive) writeEntry(w *zip.Writer, name, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info.Mode()&0111 != 0 {
+		mode = 0755
+	}
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: archiveTime,
+	}
+	header.SetMode(mode)
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entry, err := w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, f)
+	return err
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveReproducible(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]os.FileMode{"orders.py": 0644, "bin/tool": 0755}
+	for name, mode := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+		// the umask may have dropped bits
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(dest string) string {
+		archive := NewArchive()
+		if err := archive.AddDir(src, ""); err != nil {
+			t.Fatal(err)
+		}
+		sha, err := archive.Write(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha
+	}
+	out := t.TempDir()
+	first := write(filepath.Join(out, "first.zip"))
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "orders.py"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if second := write(filepath.Join(out, "second.zip")); second != first {
+		t.Errorf("Write() after an mtime change = %s, want %s", second, first)
+	}
+
+	r, err := zip.OpenReader(filepath.Join(out, "second.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if want := files[f.Name]; f.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", f.Name, f.Mode().Perm(), want)
+		}
+		if !f.Modified.Equal(archiveTime) {
+			t.Errorf("%s was modified at %v, want %v", f.Name, f.Modified, archiveTime)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"chefcli/recipe"
+)
+
+// containerEngines are the container engines a layer can be built with, in
+// the order they are looked for when none is chosen.
+var containerEngines = []string{"docker", "podman", "nerdctl"}
+
+// layerBuilder installs the packages of a requirements file into the folder
+// of a layer.
+type layerBuilder interface {
+	// Describe says how the packages are built, for output.
+	Describe() string
+	// Build installs the packages of requirements into target, both relative
+	// to the current folder.
+	Build(cb *recipe.Cookbook, requirements, target string) error
+}
+
+// Function to get the name of the layer builder. Flags win over the recipe.
+func layerBuilderName(cb *recipe.Cookbook) string {
+	if builderFlag != "" {
+		return builderFlag
+	}
+	if cb.Builder != "" {
+		return cb.Builder
+	}
+	return "container"
+}
+
+// Function to pick the layer builder.
+func newLayerBuilder(cb *recipe.Cookbook) (layerBuilder, error) {
+	builder := layerBuilderName(cb)
+	switch builder {
+	case "container":
+		engine := cb.Engine
+		if engineFlag != "" {
+			engine = engineFlag
+		}
+		return newContainerBuilder(engine)
+	case "pip":
+		wheelhouse := cb.Wheelhouse
+		if wheelhouseFlag != "" {
+			wheelhouse = wheelhouseFlag
+		}
+		return &pipBuilder{wheelhouse: wheelhouse}, nil
+	}
+	return nil, fmt.Errorf("unknown builder %s. Use container or pip", builder)
+}
+
+// containerBuilder runs pip inside the SAM build image of the runtime with
+// docker, podman or nerdctl, see
+// https://aws.amazon.com/premiumsupport/knowledge-center/lambda-layer-simulated-docker/
+type containerBuilder struct {
+	engine string
+}
+
+// Function to set up a container builder, looking for an installed engine
+// when none is given.
+func newContainerBuilder(engine string) (*containerBuilder, error) {
+	if engine == "" {
+		for _, e := range containerEngines {
+			if _, err := exec.LookPath(e); err == nil {
+				return &containerBuilder{engine: e}, nil
+			}
+		}
+		return nil, fmt.Errorf("no container engine found, install one of %s or use the pip builder", strings.Join(containerEngines, ", "))
+	}
+	for _, e := range containerEngines {
+		if e == engine {
+			return &containerBuilder{engine: engine}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown container engine %s. Use one of %s", engine, strings.Join(containerEngines, ", "))
+}
+
+func (b *containerBuilder) Describe() string {
+	return "with " + b.engine
+}
+
+func (b *containerBuilder) Build(cb *recipe.Cookbook, requirements, target string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	volume := wd + ":/var/task"
+	if b.engine == "podman" {
+		// relabel the folder so rootless podman can write to it under SELinux
+		volume += ":Z"
+	}
+
+	image := buildImage(cb)
+	args := []string{
+		"run", "--rm",
+		"-v", volume,
+		"-w", "/var/task",
+		"--platform", containerPlatform(cb),
+		image,
+		"/bin/sh", "-c", "pip install -r " + requirements + " -t " + target,
+	}
+	fmt.Println("Building in " + image + ".")
+
+	command := exec.Command(b.engine, args...)
+	command.Stdout = &layerout
+	command.Stderr = &layerstderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, layerstderr.String())
+	}
+	return nil
+}
+
+// Function to get the runtime the layer packages are built for, the first
+// the layer is compatible with.
+func layerRuntime(cb *recipe.Cookbook) string {
+	return cb.LayerRuntimes()[0]
+}
+
+// Function to get the SAM build image of the layer runtime.
+func buildImage(cb *recipe.Cookbook) string {
+	return "public.ecr.aws/sam/build-" + layerRuntime(cb)
+}
+
+// Function to get the architecture the layer packages are built for, the
+// first the layer is compatible with.
+func layerArchitecture(cb *recipe.Cookbook) string {
+	if architectures := cb.LayerArchitectures(); len(architectures) > 0 {
+		return architectures[0]
+	}
+	return "x86_64"
+}
+
+// Function to get the container platform matching the layer architecture.
+func containerPlatform(cb *recipe.Cookbook) string {
+	if layerArchitecture(cb) == "arm64" {
+		return "linux/arm64"
+	}
+	return "linux/amd64"
+}
+
+// pipBuilder installs the layer requirements with the local pip. Only
+// manylinux wheels for the Lambda platform are accepted, so the packages
+// match the Lambda environment without building in a container. With a
+// wheelhouse, pip installs offline from that folder only.
+type pipBuilder struct {
+	wheelhouse string
+}
+
+func (b *pipBuilder) Describe() string {
+	return "with the local pip"
+}
+
+func (b *pipBuilder) Build(cb *recipe.Cookbook, requirements, target string) error {
+	pip, err := exec.LookPath("pip3")
+	if err != nil {
+		if pip, err = exec.LookPath("pip"); err != nil {
+			return fmt.Errorf("the pip builder needs pip3 or pip on your PATH")
+		}
+	}
+
+	args := []string{
+		"install",
+		"-r", requirements,
+		"--target", target,
+		"--platform", lambdaPlatform(cb),
+		"--implementation", "cp",
+		"--python-version", strings.TrimPrefix(layerRuntime(cb), "python"),
+		"--only-binary=:all:",
+		"--upgrade",
+	}
+	if b.wheelhouse != "" {
+		args = append(args, "--no-index", "--find-links", b.wheelhouse)
+	}
+	fmt.Println("Installing for " + lambdaPlatform(cb) + ".")
+
+	command := exec.Command(pip, args...)
+	command.Stdout = &layerout
+	command.Stderr = &layerstderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, layerstderr.String())
+	}
+	return nil
+}
+
+// Function to get the pip platform tag matching the layer architecture.
+func lambdaPlatform(cb *recipe.Cookbook) string {
+	if layerArchitecture(cb) == "arm64" {
+		return "manylinux2014_aarch64"
+	}
+	return "manylinux2014_x86_64"
+}
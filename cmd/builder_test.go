@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"testing"
+
+	"chefcli/recipe"
+)
+
+func TestLayerPlatform(t *testing.T) {
+	tests := []struct {
+		name       string
+		cb         recipe.Cookbook
+		pip, image string
+	}{
+		{"default", recipe.Cookbook{}, "manylinux2014_x86_64", "linux/amd64"},
+		{"function architecture", recipe.Cookbook{Architectures: []string{"arm64"}}, "manylinux2014_aarch64", "linux/arm64"},
+		{"layer architecture", recipe.Cookbook{CompatibleArchitectures: []string{"arm64"}}, "manylinux2014_aarch64", "linux/arm64"},
+		{"layer wins", recipe.Cookbook{Architectures: []string{"arm64"}, CompatibleArchitectures: []string{"x86_64"}}, "manylinux2014_x86_64", "linux/amd64"},
+	}
+	for _, tt := range tests {
+		if got := lambdaPlatform(&tt.cb); got != tt.pip {
+			t.Errorf("%s: lambdaPlatform() = %s, want %s", tt.name, got, tt.pip)
+		}
+		if got := containerPlatform(&tt.cb); got != tt.image {
+			t.Errorf("%s: containerPlatform() = %s, want %s", tt.name, got, tt.image)
+		}
+	}
+}
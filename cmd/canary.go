@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+var (
+	canaryFlag   string
+	stepFlag     string
+	intervalFlag time.Duration
+	alarmFlag    []string
+	checkFlag    string
+)
+
+// canaryPlan is how traffic moves to a new version: the first weight, the
+// weight added at every step and the wait before each step, all weights in
+// percent.
+type canaryPlan struct {
+	Start    float64
+	Step     float64
+	Interval time.Duration
+}
+
+// Function to read the canary flags, returning nil without --canary.
+func canaryFlags() (*canaryPlan, error) {
+	if canaryFlag == "" {
+		return nil, nil
+	}
+	start, err := parsePercent(canaryFlag)
+	if err != nil || start <= 0 || start >= 100 {
+		return nil, fmt.Errorf("--canary must be a percentage between 0%% and 100%%, got %s", canaryFlag)
+	}
+	step, err := parsePercent(stepFlag)
+	if err != nil || step <= 0 || step > 100 {
+		return nil, fmt.Errorf("--step must be a percentage between 0%% and 100%%, got %s", stepFlag)
+	}
+	if len(alarmFlag) == 0 && checkFlag == "" {
+		return nil, fmt.Errorf("--canary needs a health check, give CloudWatch alarms with --alarm or a command with --check")
+	}
+	if intervalFlag < 0 {
+		return nil, fmt.Errorf("--interval must not be negative, got %s", intervalFlag)
+	}
+	return &canaryPlan{Start: start, Step: step, Interval: intervalFlag}, nil
+}
+
+// Function to parse a percentage such as 10% or 12.5.
+func parsePercent(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+}
+
+// Function to shift the traffic of the recipe alias to a new version step
+// by step. The health checks run after every interval, and any failure
+// sends all traffic back to the version the alias pointed at before.
+func canaryDeploy(out io.Writer, svc *lambda.Lambda, cb *recipe.Cookbook, version string, plan *canaryPlan) error {
+	current, err := svc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String(cb.Function),
+		Name:         aws.String(cb.Alias),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		fmt.Fprintf(out, "Alias %s does not exist yet, there is no traffic to shift.\n", cb.Alias)
+		return pointAlias(out, svc, cb.Function, cb.Alias, version)
+	}
+	if err != nil {
+		return err
+	}
+	stable := aws.StringValue(current.FunctionVersion)
+	if stable == "$LATEST" {
+		// $LATEST already runs the new code and takes no routing config
+		fmt.Fprintf(out, "Alias %s points at $LATEST, there is no published version to shift traffic from.\n", cb.Alias)
+		return pointAlias(out, svc, cb.Function, cb.Alias, version)
+	}
+	if stable == version {
+		fmt.Fprintf(out, "Alias %s already points at version %s.\n", cb.Alias, version)
+		return nil
+	}
+
+	for weight := plan.Start; weight < 100; weight += plan.Step {
+		_, err := svc.UpdateAlias(&lambda.UpdateAliasInput{
+			FunctionName:    aws.String(cb.Function),
+			Name:            aws.String(cb.Alias),
+			FunctionVersion: aws.String(stable),
+			RoutingConfig: &lambda.AliasRoutingConfiguration{
+				AdditionalVersionWeights: map[string]*float64{version: aws.Float64(weight / 100)},
+			},
+		})
+		if err != nil {
+			return rollback(out, svc, cb, stable, err)
+		}
+		fmt.Fprintf(out, "Alias %s sends %g%% of the traffic to version %s, checking again in %s.\n", cb.Alias, weight, version, plan.Interval)
+		time.Sleep(plan.Interval)
+
+		if err := canaryHealthy(out, cb, version, weight); err != nil {
+			return rollback(out, svc, cb, stable, err)
+		}
+	}
+
+	if err := pointAlias(out, svc, cb.Function, cb.Alias, version); err != nil {
+		return rollback(out, svc, cb, stable, err)
+	}
+	return nil
+}
+
+// Function to send all traffic of the alias back to the stable version.
+func rollback(out io.Writer, svc *lambda.Lambda, cb *recipe.Cookbook, stable string, cause error) error {
+	fmt.Fprintf(out, "Canary failed: %v. Rolling alias %s back to version %s.\n", cause, cb.Alias, stable)
+	if err := pointAlias(out, svc, cb.Function, cb.Alias, stable); err != nil {
+		return fmt.Errorf("canary failed: %v, and the rollback to version %s failed too: %v", cause, stable, err)
+	}
+	return fmt.Errorf("canary failed and was rolled back: %v", cause)
+}
+
+// Function to run the canary health checks: the CloudWatch alarms given
+// with --alarm must not be in ALARM and the --check command must succeed.
+func canaryHealthy(out io.Writer, cb *recipe.Cookbook, version string, weight float64) error {
+	if len(alarmFlag) > 0 {
+		svc := cloudwatch.New(newSession(), endpointConfig("cloudwatch"))
+		result, err := svc.DescribeAlarms(&cloudwatch.DescribeAlarmsInput{
+			AlarmNames: aws.StringSlice(alarmFlag),
+			AlarmTypes: aws.StringSlice([]string{cloudwatch.AlarmTypeMetricAlarm, cloudwatch.AlarmTypeCompositeAlarm}),
+		})
+		if err != nil {
+			return err
+		}
+		states := map[string]string{}
+		for _, alarm := range result.MetricAlarms {
+			states[aws.StringValue(alarm.AlarmName)] = aws.StringValue(alarm.StateValue)
+		}
+		for _, alarm := range result.CompositeAlarms {
+			states[aws.StringValue(alarm.AlarmName)] = aws.StringValue(alarm.StateValue)
+		}
+		for _, name := range alarmFlag {
+			state, ok := states[name]
+			if !ok {
+				return fmt.Errorf("alarm %s does not exist", name)
+			}
+			if state == cloudwatch.StateValueAlarm {
+				return fmt.Errorf("alarm %s is in ALARM", name)
+			}
+		}
+	}
+
+	if checkFlag != "" {
+		command := exec.Command("/bin/sh", "-c", checkFlag)
+		command.Env = append(os.Environ(),
+			"CHEFCLI_FUNCTION="+cb.Function,
+			"CHEFCLI_ALIAS="+cb.Alias,
+			"CHEFCLI_VERSION="+version,
+			"CHEFCLI_WEIGHT="+strconv.FormatFloat(weight, 'f', -1, 64),
+		)
+		command.Stdout = out
+		command.Stderr = out
+		if err := command.Run(); err != nil {
+			return fmt.Errorf("check command failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanaryFlags(t *testing.T) {
+	defer func() {
+		canaryFlag, stepFlag, intervalFlag, alarmFlag, checkFlag = "", "10%", 5*time.Minute, nil, ""
+	}()
+	tests := []struct {
+		canary, step string
+		interval     time.Duration
+		alarms       []string
+		check        string
+		want         *canaryPlan
+		err          string
+	}{
+		{canary: "", want: nil},
+		{canary: "10%", step: "20%", interval: time.Minute, alarms: []string{"errors"}, want: &canaryPlan{Start: 10, Step: 20, Interval: time.Minute}},
+		{canary: "12.5", step: "100", check: "./smoke.sh", want: &canaryPlan{Start: 12.5, Step: 100}},
+		{canary: "0%", step: "10%", check: "true", err: "--canary must be a percentage"},
+		{canary: "100%", step: "10%", check: "true", err: "--canary must be a percentage"},
+		{canary: "ten", step: "10%", check: "true", err: "--canary must be a percentage"},
+		{canary: "10%", step: "0", check: "true", err: "--step must be a percentage"},
+		{canary: "10%", step: "150%", check: "true", err: "--step must be a percentage"},
+		{canary: "10%", step: "10%", err: "--canary needs a health check"},
+		{canary: "10%", step: "10%", interval: -time.Second, check: "true", err: "--interval must not be negative"},
+	}
+	for _, tt := range tests {
+		canaryFlag, stepFlag, intervalFlag, alarmFlag, checkFlag = tt.canary, tt.step, tt.interval, tt.alarms, tt.check
+		plan, err := canaryFlags()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("canaryFlags() with --canary %s --step %s error = %v, want %q", tt.canary, tt.step, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("canaryFlags() with --canary %s --step %s error = %v", tt.canary, tt.step, err)
+			continue
+		}
+		if (plan == nil) != (tt.want == nil) || plan != nil && *plan != *tt.want {
+			t.Errorf("canaryFlags() with --canary %s --step %s = %+v, want %+v", tt.canary, tt.step, plan, tt.want)
+		}
+	}
+}
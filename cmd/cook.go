@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -21,6 +22,8 @@ var (
 	profileFlag string
 	yesFlag     bool
 	deleteFlag  bool
+	recipeFlag  string
+	stageFlag   string
 )
 
 var cookCmd = &cobra.Command{
@@ -213,6 +216,9 @@ func init() {
 	cookCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "AWS Profile Description.")
 	cookCmd.PersistentFlags().BoolVar(&yesFlag, "yes", false, "Yes Flag Description.")
 	cookCmd.PersistentFlags().BoolVar(&deleteFlag, "delete", false, "Delete Flag Description.")
+	cookCmd.PersistentFlags().StringVar(&recipeFlag, "recipe", "", "path to the recipe file (defaults to recipe.yml or recipe.yaml).")
+	cookCmd.PersistentFlags().StringVar(&stageFlag, "stage", "", "recipe stage to overlay on the base recipe, e.g. prod.")
+	cookCmd.PersistentFlags().DurationVar(&waitTimeoutFlag, "wait-timeout", 5*time.Minute, "how long to wait for a function update to finish.")
 
 	cookCmd.AddCommand(cookLambdaCmd)
 	cookCmd.AddCommand(cookTerraformCmd)
@@ -1,34 +1,71 @@
 package cmd
 
 import (
-	"archive/zip"
 	"bufio"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
 var (
-	lambdaCookbook   string
-	functionFile     string
-	lambdaVenvFolder string
+	onlyFlag      []string
+	dirsFlag      []string
+	parallelFlag  int
+	forceFlag     bool
+	publishFlag   bool
+	buildOnlyFlag bool
+	lambdaCanary  *canaryPlan
 )
 
+// lambdaJob is a single function to cook, from the recipe found in Dir.
+type lambdaJob struct {
+	Dir      string
+	Cookbook *recipe.Cookbook
+	Skip     bool
+}
+
+// lambdaResult records what happened to a single function during a cook.
+type lambdaResult struct {
+	Dir      string
+	Function string
+	Status   string
+	Err      error
+}
+
 var cookLambdaCmd = &cobra.Command{
 	Use:     "lambda",
 	Short:   "Cook your Lambda code",
-	Long:    "Cook your Lambda code from the current folder.",
-	Example: "chefcli cook lambda",
+	Long:    "Cook your Lambda code from the current folder, or from every folder given with --dirs. When the recipe lists functions, every function is cooked unless --only is given. Each function is created when it does not exist yet and updated otherwise, --new or --update insist on one of them and --build-only only builds the ZIP archive.",
+	Example: "chefcli cook lambda --dirs orders,payments --parallel 4 --update\nchefcli cook lambda --update --canary 10% --step 30% --interval 5m --alarm orders-errors",
 	ValidArgs: []string{
 		"venv",
 		"new",
 		"update",
+		"only",
+		"dirs",
+		"parallel",
+		"force",
+		"build-only",
+		"publish",
+		"canary",
+		"step",
+		"interval",
+		"alarm",
+		"check",
 	},
 	Args: cobra.OnlyValidArgs,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -36,175 +73,471 @@ var cookLambdaCmd = &cobra.Command{
 		// Make sure we call Cook Lambda.
 		fmt.Println("Cooking Lambda function.")
 
-		// cb is going to be our Cookbook
-		cb := Cookbook{}
+		if buildOnlyFlag && (New || Update) {
+			fmt.Println("--build-only cannot be combined with --new or --update.")
+			os.Exit(1)
+		}
+
+		if parallelFlag < 1 {
+			fmt.Println("--parallel must be at least 1.")
+			os.Exit(1)
+		}
 
-		// Check if recipe.yml exists and ensure the Cookbook is picked up
-		if !FileExists("recipe.yml") && !FileExists("recipe.yaml") {
-			fmt.Println("There is no recipe.yml or recipe.yaml file present. Please set one up.")
-			os.Exit(0)
+		// Collect the functions to cook from every recipe
+		var jobs []lambdaJob
+		var results []lambdaResult
+		if len(dirsFlag) == 0 {
+			jobs = lambdaJobs("", LoadRecipe())
 		} else {
-			if FileExists("recipe.yml") {
-				lambdaCookbook, err := ioutil.ReadFile("recipe.yml")
-				err = yaml.Unmarshal(lambdaCookbook, &cb)
+			for _, dir := range dirsFlag {
+				cb, err := loadRecipe(filepath.Join(dir, recipeFlag))
 				if err != nil {
-					log.Fatalf("Unmarshal: %v", err)
+					fmt.Println(dir + ": " + redact(err.Error()))
+					results = append(results, lambdaResult{Dir: dir, Status: "failed", Err: err})
+					continue
 				}
-				cb.Recipe = lambdaCookbook
-			} else {
-				lambdaCookbook, err := ioutil.ReadFile("recipe.yaml")
-				err = yaml.Unmarshal(lambdaCookbook, &cb)
-				if err != nil {
-					log.Fatalf("Unmarshal: %v", err)
+				jobs = append(jobs, lambdaJobs(dir, cb)...)
+			}
+			for _, name := range onlyFlag {
+				if !hasLambdaJob(jobs, name) {
+					fmt.Printf("There is no function %q in the recipes of %s.\n", name, strings.Join(dirsFlag, ", "))
+					os.Exit(1)
 				}
-				cb.Recipe = lambdaCookbook
 			}
+		}
 
-			// Check Function name
-			if cb.Function == "" {
-				fmt.Println("There is no Function name. Plese supply a function name in your Recipe.")
-				os.Exit(1)
+		// A canary publishes a version and shifts the traffic of the recipe alias to it
+		plan, err := canaryFlags()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if plan != nil {
+			for _, job := range jobs {
+				if !job.Skip && job.Cookbook.Alias == "" {
+					fmt.Println(job.Cookbook.Function + ": a canary needs an alias in the recipe to shift traffic on.")
+					os.Exit(1)
+				}
 			}
+			lambdaCanary, publishFlag = plan, true
+		}
 
-			// Check Handler name
-			if cb.Handler == "" {
-				fmt.Println("There is no Handler name. Plese supply a handler name in your Recipe.")
+		//Ensuring that the user wants to leverage the Virtual Environment in the ZIP
+		if Venv {
+			fmt.Println("Virtual environments are for local development. Are you sure you want to include them in your Lambda ZIP package? [yes/no]")
+			input := bufio.NewScanner(os.Stdin)
+			input.Scan()
+			if input.Text() != "yes" && input.Text() != "no" {
+				fmt.Println("Please type either 'yes' or 'no'.")
 				os.Exit(1)
-			} else {
-				cb.Handler = cb.Function + "." + cb.Handler
 			}
+			if input.Text() == "no" {
+				fmt.Println("Understood. Use 'chefcli cook layer' to build the relevant layers for your Lambda.")
+				os.Exit(1)
+			}
+			fmt.Println("Understood. Proceeding with the virtual environment...")
+		}
 
-			// Check the Runtime
-			if cb.Runtime == "" {
-				fmt.Println("There is no Runtime specified.")
-				fmt.Println("Defaulting to Python 3.8 .")
-				cb.Runtime = "python3.8"
+		var svc *lambda.Lambda
+		if !buildOnlyFlag {
+			// Initialize a session that the SDK will use to load
+			// credentials from the shared credentials file ~/.aws/credentials.
+			sess := session.Must(session.NewSessionWithOptions(session.Options{
+				SharedConfigState: session.SharedConfigEnable,
+			}))
+			svc = lambda.New(sess, endpointConfig("lambda"))
+		}
+
+		if printLambdaSummary(append(results, runLambdaJobs(svc, jobs, parallelFlag)...)) {
+			os.Exit(1)
+		}
+	},
+}
+
+// Function to turn a recipe into jobs, marking the functions not picked with --only as skipped.
+func lambdaJobs(dir string, cb *recipe.Cookbook) []lambdaJob {
+	var jobs []lambdaJob
+	for _, fn := range cb.All() {
+		jobs = append(jobs, lambdaJob{Dir: dir, Cookbook: fn, Skip: len(onlyFlag) > 0})
+	}
+	for _, name := range onlyFlag {
+		fn, err := cb.Lookup(name)
+		if err != nil {
+			if len(dirsFlag) > 0 {
+				// a function may live in any of the folders
+				continue
 			}
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for i := range jobs {
+			if jobs[i].Cookbook == fn {
+				jobs[i].Skip = false
+			}
+		}
+	}
+	return jobs
+}
 
-			// Check for the Zip File Name
-			if cb.Zipfile == "" {
-				if FileExists(cb.Function + ".py") {
-					cb.Zipfile = cb.Function
-					fmt.Println("No Zip file found. Function file found. Checking for Virtual Env.")
+// Function to check if a function is part of the jobs.
+func hasLambdaJob(jobs []lambdaJob, function string) bool {
+	for _, job := range jobs {
+		if job.Cookbook.Function == function {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to cook jobs with a pool of workers. Every job runs to completion
+// even when others fail, output is prefixed with the function name when
+// more than one function is cooked.
+func runLambdaJobs(svc *lambda.Lambda, jobs []lambdaJob, workers int) []lambdaResult {
+	results := make([]lambdaResult, len(jobs))
+	queue := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				job := jobs[i]
+				results[i] = lambdaResult{Dir: job.Dir, Function: job.Cookbook.Function, Status: "skipped"}
+				if job.Skip {
+					continue
 				}
-				// Check for the dependencies folder
-				if _, err := os.Stat(cb.Function + "/lib/python3.8/site-packages"); os.IsNotExist(err) {
-					fmt.Println("No Virtual Env found. Exiting.")
-					CheckError(err)
-					os.Exit(1)
+				prefix := ""
+				if len(jobs) > 1 {
+					prefix = "[" + filepath.Join(job.Dir, job.Cookbook.Function) + "] "
 				}
+				out := newPrefixWriter(os.Stdout, &mu, prefix)
+				status, err := cookLambda(out, svc, job.Dir, job.Cookbook)
+				if err != nil {
+					fmt.Fprintln(out, "Error: "+err.Error())
+					status = "failed"
+				}
+				out.Flush()
+				results[i].Status = status
+				results[i].Err = err
 			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
 
-			//Ensuring that the user wants to leverage the Virtual Environment in the ZIP
-			if Venv {
-				fmt.Println("Virtual environments are for local development. Are you sure you want to include them in your Lambda ZIP package? [yes/no]")
-				input := bufio.NewScanner(os.Stdin)
-				input.Scan()
-				//				fmt.Printf("%v", input.Text())
-				if input.Text() != "yes" && input.Text() != "no" {
-					fmt.Println("Please type either 'yes' or 'no'.")
-					os.Exit(1)
-				}
-				if input.Text() == "no" {
-					fmt.Println("Understood. Use 'chefcli cook layer' to build the relevant layers for your Lambda.")
-					os.Exit(1)
-				} else {
-					fmt.Println("Understood. Proceeding with the virtual environment...")
-					// set up the Venv variable with the path to the site-packages
-					lambdaVenvFolder = cb.Function + "/lib/python3.8/site-packages"
-				}
+// Function to build, and with --new or --update deploy, a single function
+// whose recipe lives in dir. It returns whether the function was created,
+// updated or only built.
+func cookLambda(out io.Writer, svc *lambda.Lambda, dir string, cb *recipe.Cookbook) (string, error) {
+	fmt.Fprintln(out, "Cooking "+cb.Function+".")
+
+	// Check for the Zip File Name
+	venvFolder := filepath.Join(dir, cb.Dir, cb.Module, cb.SitePackages())
+	functionFile := filepath.Join(dir, cb.Dir, cb.Module+".py")
+	if cb.Zipfile == "" {
+		if len(cb.Source) > 0 {
+			cb.Zipfile = cb.Function
+			fmt.Fprintln(out, "No Zip file found. Source found.")
+		} else if FileExists(functionFile) {
+			cb.Zipfile = cb.Function
+			fmt.Fprintln(out, "No Zip file found. Function file found.")
+		}
+	}
+	// Check for the dependencies folder
+	if Venv {
+		fmt.Fprintln(out, "Checking for Virtual Env.")
+		if _, err := os.Stat(venvFolder); os.IsNotExist(err) {
+			return "", fmt.Errorf("no Virtual Env found: %w", err)
+		}
+	}
+
+	// Collect the files of the archive
+	archive := NewArchive()
+	if len(cb.Source) > 0 {
+		root := filepath.Join(dir, cb.Dir)
+		skip := sourceSkip(root)
+		for _, source := range cb.Source {
+			if err := archive.AddPath(root, source, skip); err != nil {
+				return "", err
 			}
-			//setting up the file name for the Python Lambda
-			functionFile = cb.Function + ".py"
+		}
+	} else {
+		archive.AddFile(functionFile, filepath.Base(functionFile))
+	}
+	if Venv {
+		if err := archive.AddDir(venvFolder, ""); err != nil {
+			return "", err
+		}
+	}
+	if err := trimArchive(out, archive, dir, cb); err != nil {
+		return "", err
+	}
+	// Make sure the handler can be imported and called once deployed
+	module, ok := archive.Source(cb.HandlerModule())
+	if !ok {
+		return "", fmt.Errorf("the handler module %s of %s is not in the archive", cb.HandlerModule(), cb.Handler)
+	}
+	if err := checkHandler(module, cb.HandlerName()); err != nil {
+		return "", err
+	}
+	// the archive is named after the recipe zipfile, or the function
+	zipName := cb.Zipfile
+	if zipName == "" {
+		zipName = cb.Function
+	}
+	zipPath := filepath.Join(dir, zipName+".zip")
+	sha, err := archive.Write(zipPath)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(out, "ZIP archive is ready. The name of the archive is "+zipPath)
+	fmt.Fprintln(out, "SHA-256: "+sha)
+
+	if buildOnlyFlag {
+		return "built", nil
+	}
+
+	// Check For ARN
+	if err := cb.RequireARN(); err != nil {
+		return "", err
+	}
+
+	contents, err := ioutil.ReadFile(zipPath)
+	if err != nil {
+		return "", err
+	}
+
+	// Without --new or --update, create the function when it does not exist yet and update it otherwise
+	create, update := New, Update
+	var remote *lambda.GetFunctionOutput
+	if !New && !Update {
+		remote, err = svc.GetFunction(&lambda.GetFunctionInput{FunctionName: &cb.Function})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+			fmt.Fprintln(out, cb.Function+" does not exist yet, creating it.")
+			create, remote = true, nil
+		} else if err != nil {
+			return "", err
+		} else {
+			update = true
+		}
+	}
 
-			// Get a Buffer to Write To
-			outFile, err := os.Create(cb.Function + ".zip")
+	if create {
+		code, err := stageArchive(out, cb, cb.Function, contents)
+		if err != nil {
+			return "", err
+		}
+
+		lambdaArgs := &lambda.CreateFunctionInput{
+			Code:             code.functionCode(),
+			FunctionName:     &cb.Function,
+			Handler:          &cb.Handler,
+			Role:             &cb.ARN,
+			Runtime:          &cb.Runtime,
+			MemorySize:       lambdaMemory(cb),
+			Timeout:          lambdaTimeout(cb),
+			Environment:      lambdaEnvironment(cb),
+			EphemeralStorage: lambdaEphemeralStorage(cb),
+			Architectures:    lambdaArchitectures(cb),
+		}
+		if len(cb.Tags) > 0 {
+			lambdaArgs.Tags = aws.StringMap(cb.Tags)
+		}
+
+		result, err := svc.CreateFunction(lambdaArgs)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(out, result)
+		if err := waitForFunction(out, svc, cb.Function); err != nil {
+			return "", err
+		}
+		if publishFlag {
+			version, err := publishVersion(out, svc, dir, cb, lambdaCanary)
 			if err != nil {
-				fmt.Println(err)
+				return "", err
 			}
-			defer outFile.Close()
-			// Create a new zip archive.
-			writer := zip.NewWriter(outFile)
-			// Add some files to the archive.
-			AddFiles(writer, functionFile, "/")
-			if Venv {
-				AddFiles(writer, lambdaVenvFolder, "")
+			return "created, version " + version, nil
+		}
+		return "created", nil
+	}
+
+	if update {
+		status := "updated"
+
+		// an update still in progress would reject ours
+		if err := waitForChange(out, svc, cb.Function); err != nil {
+			return "", err
+		}
+
+		// Skip the code update when the deployed code matches the archive
+		unchanged := false
+		if !forceFlag {
+			if remote == nil {
+				remote, err = svc.GetFunction(&lambda.GetFunctionInput{FunctionName: &cb.Function})
+				if err != nil {
+					return "", err
+				}
 			}
+			unchanged = codeUnchanged(remote.Configuration, codeSha256(contents), cb)
+		}
 
+		if unchanged {
+			fmt.Fprintln(out, "Code is unchanged, skipping the code update. Use --force to update anyway.")
+			status = "code unchanged"
+		} else {
+			code, err := stageArchive(out, cb, cb.Function, contents)
 			if err != nil {
-				fmt.Println(err)
+				return "", err
 			}
-			// Make sure to check the error on Close.
-			err = writer.Close()
+			input := &lambda.UpdateFunctionCodeInput{
+				FunctionName:  &cb.Function,
+				ZipFile:       code.ZipFile,
+				S3Bucket:      code.Bucket,
+				S3Key:         code.Key,
+				Architectures: lambdaArchitectures(cb),
+			}
+
+			result, err := svc.UpdateFunctionCode(input)
 			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("ZIP archive is ready. The name of the archive is " + cb.Function + ".zip")
+				return "", err
 			}
+			fmt.Fprintln(out, result)
 
-			// Check For ARN, Function and Handler names
-			if cb.ARN == "" {
-				fmt.Println("You must supply an ARN.")
-				os.Exit(1)
+			// a configuration update is rejected while the code update is still in progress
+			if err := waitForFunction(out, svc, cb.Function); err != nil {
+				return "", err
 			}
+		}
 
-			contents, err := ioutil.ReadFile(cb.Zipfile + ".zip")
-			CheckError(err)
-			// Only deploy if the Now flag is set
-			if New {
-				// Initialize a session that the SDK will use to load
-				// credentials from the shared credentials file ~/.aws/credentials.
-				sess := session.Must(session.NewSessionWithOptions(session.Options{
-					SharedConfigState: session.SharedConfigEnable,
-				}))
+		// Reconcile the configuration with the recipe
+		configInput := &lambda.UpdateFunctionConfigurationInput{
+			FunctionName:     &cb.Function,
+			Handler:          &cb.Handler,
+			Role:             &cb.ARN,
+			Runtime:          &cb.Runtime,
+			MemorySize:       lambdaMemory(cb),
+			Timeout:          lambdaTimeout(cb),
+			Environment:      lambdaEnvironment(cb),
+			EphemeralStorage: lambdaEphemeralStorage(cb),
+		}
+		configResult, err := svc.UpdateFunctionConfiguration(configInput)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(out, configResult)
+		if err := waitForFunction(out, svc, cb.Function); err != nil {
+			return "", err
+		}
 
-				svc := lambda.New(sess)
+		if len(cb.Tags) > 0 {
+			_, err = svc.TagResource(&lambda.TagResourceInput{
+				Resource: configResult.FunctionArn,
+				Tags:     aws.StringMap(cb.Tags),
+			})
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(out, "Tagged %s with %d tag%v.\n", cb.Function, len(cb.Tags), pluralize(len(cb.Tags)))
+		}
 
-				lambdaCode := &lambda.FunctionCode{
-					//	S3Bucket:        &cb.Bucket,
-					//	S3Key:           &cb.Zipfile,
-					//	S3ObjectVersion: aws.String("1"),
-					ZipFile: contents,
-				}
+		if publishFlag {
+			version, err := publishVersion(out, svc, dir, cb, lambdaCanary)
+			if err != nil {
+				return "", err
+			}
+			status += ", version " + version
+		}
+		return status, nil
+	}
 
-				lambdaArgs := &lambda.CreateFunctionInput{
-					Code:         lambdaCode,
-					FunctionName: &cb.Function,
-					Handler:      &cb.Handler,
-					Role:         &cb.ARN,
-					Runtime:      &cb.Runtime,
-				}
+	return "built", nil
+}
 
-				result, err := svc.CreateFunction(lambdaArgs)
-				if err != nil {
-					CheckError(err)
-				} else {
-					fmt.Println(result)
-				}
-			}
+// Function to print the summary table of a cook. It returns true if any function failed.
+func printLambdaSummary(results []lambdaResult) bool {
+	failed := false
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIR\tFUNCTION\tSTATUS\tERROR")
+	for _, r := range results {
+		msg := ""
+		if r.Err != nil {
+			failed = true
+			msg = redact(strings.SplitN(r.Err.Error(), "\n", 2)[0])
+		}
+		dir := r.Dir
+		if dir == "" {
+			dir = "."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", dir, r.Function, r.Status, msg)
+	}
+	w.Flush()
+	return failed
+}
+
+// Function to check if the deployed code matches the archive. A change of
+// architecture needs a code update even when the code is the same.
+func codeUnchanged(remote *lambda.FunctionConfiguration, sha string, cb *recipe.Cookbook) bool {
+	if remote == nil || aws.StringValue(remote.CodeSha256) != sha {
+		return false
+	}
+	if len(cb.Architectures) > 0 {
+		remoteArchitectures := aws.StringValueSlice(remote.Architectures)
+		if len(remoteArchitectures) == 0 {
+			remoteArchitectures = []string{"x86_64"}
+		}
+		if strings.Join(remoteArchitectures, ",") != strings.Join(cb.Architectures, ",") {
+			return false
+		}
+	}
+	return true
+}
 
-			if Update {
-				// Initialize a session that the SDK will use to load
-				// credentials from the shared credentials file ~/.aws/credentials.
-				sess := session.Must(session.NewSessionWithOptions(session.Options{
-					SharedConfigState: session.SharedConfigEnable,
-				}))
+// Function to get the memory size from the recipe, nil leaves the AWS value.
+func lambdaMemory(cb *recipe.Cookbook) *int64 {
+	if cb.Memory == 0 {
+		return nil
+	}
+	return aws.Int64(cb.Memory)
+}
 
-				svc := lambda.New(sess)
+// Function to get the timeout from the recipe, nil leaves the AWS value.
+func lambdaTimeout(cb *recipe.Cookbook) *int64 {
+	if cb.Timeout == 0 {
+		return nil
+	}
+	return aws.Int64(cb.Timeout)
+}
 
-				input := &lambda.UpdateFunctionCodeInput{
-					FunctionName: &cb.Function,
-					ZipFile:      contents,
-				}
+// Function to get the environment variables from the recipe, nil leaves the AWS value.
+func lambdaEnvironment(cb *recipe.Cookbook) *lambda.Environment {
+	if cb.Environment == nil {
+		return nil
+	}
+	return &lambda.Environment{Variables: aws.StringMap(cb.Environment)}
+}
 
-				result, err := svc.UpdateFunctionCode(input)
-				if CheckAWSError(err) {
-					os.Exit(1)
-				}
-				fmt.Println(result)
-			}
-		}
-	},
+// Function to get the ephemeral storage from the recipe, nil leaves the AWS value.
+func lambdaEphemeralStorage(cb *recipe.Cookbook) *lambda.EphemeralStorage {
+	if cb.EphemeralStorage == 0 {
+		return nil
+	}
+	return &lambda.EphemeralStorage{Size: aws.Int64(cb.EphemeralStorage)}
+}
+
+// Function to get the architectures from the recipe, nil leaves the AWS value.
+func lambdaArchitectures(cb *recipe.Cookbook) []*string {
+	if len(cb.Architectures) == 0 {
+		return nil
+	}
+	return aws.StringSlice(cb.Architectures)
 }
 
 func init() {
@@ -212,6 +545,17 @@ func init() {
 	cookLambdaCmd.PersistentFlags().BoolVar(&New, "new", false, "cook new Lambda function.")
 	cookLambdaCmd.PersistentFlags().BoolVar(&Update, "update", false, "cook update for a Lambda function.")
 	cookLambdaCmd.PersistentFlags().BoolVar(&Venv, "venv", false, "add virtual environment packages to the ZIP archive.")
+	cookLambdaCmd.PersistentFlags().StringSliceVar(&onlyFlag, "only", nil, "only cook the named functions from the recipe.")
+	cookLambdaCmd.PersistentFlags().StringSliceVar(&dirsFlag, "dirs", nil, "cook the recipes found in each of these folders.")
+	cookLambdaCmd.PersistentFlags().BoolVar(&forceFlag, "force", false, "update the code even when it matches the deployed code.")
+	cookLambdaCmd.PersistentFlags().BoolVar(&buildOnlyFlag, "build-only", false, "only build the ZIP archive, without deploying it.")
+	cookLambdaCmd.PersistentFlags().BoolVar(&publishFlag, "publish", false, "publish a version after deploying and move the recipe alias to it.")
+	cookLambdaCmd.PersistentFlags().StringVar(&canaryFlag, "canary", "", "publish a version and shift this share of the alias traffic to it first, e.g. 10%.")
+	cookLambdaCmd.PersistentFlags().StringVar(&stepFlag, "step", "10%", "share of the alias traffic added at every canary step.")
+	cookLambdaCmd.PersistentFlags().DurationVar(&intervalFlag, "interval", 5*time.Minute, "time to wait before every canary step.")
+	cookLambdaCmd.PersistentFlags().StringSliceVar(&alarmFlag, "alarm", nil, "CloudWatch alarms that roll the canary back when in ALARM.")
+	cookLambdaCmd.PersistentFlags().StringVar(&checkFlag, "check", "", "command that rolls the canary back when it fails, run after every canary step.")
+	cookLambdaCmd.PersistentFlags().IntVar(&parallelFlag, "parallel", 1, "number of functions to package and deploy at the same time.")
 
 	// keeping for reference
 	// deployLambdaCmd.MarkPersistentFlagRequired("")
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestCodeUnchanged(t *testing.T) {
+	const sha = "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="
+	deployed := func(sha string, architectures ...string) *lambda.FunctionConfiguration {
+		return &lambda.FunctionConfiguration{CodeSha256: aws.String(sha), Architectures: aws.StringSlice(architectures)}
+	}
+	tests := []struct {
+		name          string
+		remote        *lambda.FunctionConfiguration
+		architectures []string
+		want          bool
+	}{
+		{"new function", nil, nil, false},
+		{"same code", deployed(sha, "x86_64"), nil, true},
+		{"other code", deployed("other", "x86_64"), nil, false},
+		{"same architecture", deployed(sha, "arm64"), []string{"arm64"}, true},
+		{"architecture change", deployed(sha, "x86_64"), []string{"arm64"}, false},
+		{"default architecture", deployed(sha), []string{"x86_64"}, true},
+	}
+	for _, tt := range tests {
+		cb := &recipe.Cookbook{Architectures: tt.architectures}
+		if got := codeUnchanged(tt.remote, sha, cb); got != tt.want {
+			t.Errorf("%s: codeUnchanged() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,27 +1,28 @@
 package cmd
 
 import (
-	"archive/zip"
 	"bufio"
 	"bytes"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 )
 
 var (
-	layerCookbook string
-	layerFolder   string
-	layerout      bytes.Buffer
-	layerstderr   bytes.Buffer
+	layerFolder    string
+	layerout       bytes.Buffer
+	layerstderr    bytes.Buffer
+	builderFlag    string
+	wheelhouseFlag string
+	engineFlag     string
+	noCacheFlag    bool
 )
 
 var cookLayerCmd = &cobra.Command{
@@ -31,6 +32,10 @@ var cookLayerCmd = &cobra.Command{
 	Example: "chefcli cook layer",
 	ValidArgs: []string{
 		"now",
+		"builder",
+		"wheelhouse",
+		"engine",
+		"no-cache",
 	},
 	Args: cobra.OnlyValidArgs,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -39,194 +44,207 @@ var cookLayerCmd = &cobra.Command{
 		fmt.Println("Cooking Lambda Layers.")
 
 		// cb is going to be our Cookbook
-		cb := Cookbook{}
+		cb := LoadRecipe()
 
-		// Check if recipe.yml exists and ensure the Cookbook is picked up
-		if !FileExists("recipe.yml") && !FileExists("recipe.yaml") {
-			fmt.Println("There is no recipe.yml or recipe.yaml file present. Please set one up.")
-			os.Exit(0)
-		} else {
-			if FileExists("recipe.yml") {
-				layerCookbook, err := ioutil.ReadFile("recipe.yml")
-				err = yaml.Unmarshal(layerCookbook, &cb)
-				if err != nil {
-					log.Fatalf("Unmarshal: %v", err)
-				}
-				cb.Recipe = layerCookbook
-			} else {
-				layerCookbook, err := ioutil.ReadFile("recipe.yaml")
-				err = yaml.Unmarshal(layerCookbook, &cb)
-				if err != nil {
-					log.Fatalf("Unmarshal: %v", err)
-				}
-				cb.Recipe = layerCookbook
-			}
-
-			// Check Layer name
-			if cb.Layer == "" {
-				fmt.Println("There is no Layer name. Plese supply a layer name in your Recipe.")
-				os.Exit(0)
-			}
-
-			// Check Function name
-			if cb.Function == "" {
-				fmt.Println("There is no Function name. Plese supply a function name in your Recipe.")
-				os.Exit(0)
-			}
-
-			// Check Handler name
-			if cb.Handler == "" {
-				fmt.Println("There is no Handler name. Plese supply a handler name in your Recipe.")
-				os.Exit(0)
-			} else {
-				cb.Handler = cb.Function + "." + cb.Handler
-			}
+		// Check Layer name
+		if err := cb.RequireLayer(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 
-			// Check the Runtime
-			if cb.Runtime == "" {
-				fmt.Println("There is no Runtime specified.")
-				fmt.Println("Defaulting to Python 3.8 .")
-				cb.Runtime = "python3.8"
-			}
+		// the packages are built for a single platform
+		if architectures := cb.LayerArchitectures(); len(architectures) > 1 {
+			fmt.Println("A layer is built for one architecture, but the recipe lists " + strings.Join(architectures, " and ") + ". Cook a layer per architecture with a stage each.")
+			os.Exit(1)
+		}
 
-			// Check For ARN, Function and Handler names
-			if cb.ARN == "" {
-				fmt.Println("You must supply an ARN.")
-				os.Exit(0)
-			}
+		// the functions the layer is added to with --now
+		var functionNames []string
+		for _, fn := range cb.All() {
+			functionNames = append(functionNames, fn.Function)
+		}
 
-			// Check for the Zip File Name
-			if cb.Zipfile == "" {
-				if FileExists(cb.Function + ".py") {
-					cb.Zipfile = cb.Function
-					fmt.Println("No Zip file found. Function file found. Checking for Virtual Env.")
-				}
-				// Check for the dependencies folder
-				if _, err := os.Stat(cb.Function + "/lib/python3.8/site-packages"); os.IsNotExist(err) {
-					fmt.Println("No Virtual Env found. Exiting.")
-					CheckError(err)
-					os.Exit(0)
-				}
-			}
+		// name the archive after the function, or after the layer when the recipe lists functions
+		layerZip := cb.Function + "_layers.zip"
+		if cb.Function == "" {
+			layerZip = cb.Layer + "_layers.zip"
+		}
 
-			// ensure description is blank if it is empty
-			if cb.Description == "" {
-				cb.Description = ""
-			}
+		// ensure description is blank if it is empty
+		if cb.Description == "" {
+			cb.Description = ""
+		}
 
-			// set up the variables with the paths to zip
-			// Check the folder named python for layer building
-			layerFolder = "python"
-			_, err := os.Stat(layerFolder)
-			if os.IsExist(err) {
-				fmt.Println("A folder named python already exists. ChefCLI uses that folder for handling the layer cooking, please move it or delete it so that ChefCLI can use it.")
-				os.Exit(0)
-			}
-			errDir := os.MkdirAll("python/lib/"+cb.Runtime+"/site-packages", 0755)
-			CheckError(errDir)
+		// get the pinned requirements, exported from a lockfile when needed
+		requirements, err := layerRequirements(cb)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 
-			// check that a requirements.txt file exists
-			if !FileExists("requirements.txt") {
-				fmt.Println("There is no requirements.txt file present. Please set one up with the required packages for your packages.")
-				os.Exit(0)
+		// look for an archive built from the same requirements
+		key, err := layerCacheKey(cb, layerBuilderName(cb), requirements)
+		CheckError(err)
+		cachePath := ""
+		if !noCacheFlag {
+			if cachePath, err = layerCachePath(key); err != nil {
+				fmt.Println("No cache folder available, building without cache: " + err.Error())
+				cachePath = ""
 			}
+		}
 
-			// Old script, keeping this for reference
-			//			script := []byte("#!/bin/bash\nexport PKG_DIR='python'\nrm -rf ${PKG_DIR} && mkdir -p ${PKG_DIR}\ndocker run --rm -v $(pwd):/layers -w /layers lambci/lambda:build-python3.8 \\ \n pip3 install -r requirements.txt --no-deps -t ${PKG_DIR}")
-
-			// Script to leverage Docker to build layer libraries, see https://aws.amazon.com/premiumsupport/knowledge-center/lambda-layer-simulated-docker/ for more information
-			script := []byte("#!/bin/bash\ndocker run -v \"$PWD\":/var/task \"lambci/lambda:build-python3.8\" /bin/sh -c \"pip3 install -r requirements.txt -t python/lib/python3.8/site-packages/ --no-deps\"")
-
-			// write script
-			err = ioutil.WriteFile("python/get_layer_packages.sh", script, 0644)
+		if cachePath != "" && FileExists(cachePath) {
+			fmt.Println("The requirements did not change, using the cached archive " + cachePath)
+			err = copyFile(cachePath, layerZip)
 			CheckError(err)
+		} else {
+			// build in a fresh folder so no packages are left over from an earlier build.
+			// It is below the current folder for the container builder to see it.
+			buildFolder, err := ioutil.TempDir(".", layerBuildPrefix)
+			CheckError(err)
+			layerFolder = filepath.Join(buildFolder, "python")
+			target := filepath.ToSlash(filepath.Join(layerFolder, cb.LayerSitePackages()))
+			errDir := os.MkdirAll(target, 0755)
+			CheckError(errDir)
 
-			// execute command
-			command := exec.Command("/bin/sh", "python/get_layer_packages.sh")
-			command.Stdout = &layerout
-			command.Stderr = &layerstderr
-
-			// run execution
-			err = command.Run()
+			// build the layer packages
+			builder, err := newLayerBuilder(cb)
 			if err != nil {
-				fmt.Println(fmt.Sprint(err) + ": " + layerstderr.String())
-				return
+				fmt.Println(err)
+				os.Exit(1)
 			}
-			fmt.Println("Result: " + out.String())
-
-			// removing singular file as opposed to using os.RemoveAll
-			err = os.Remove("python/get_layer_packages.sh")
-			CheckError(err)
-
-			// Get a Buffer to Write To
-			outFile, err := os.Create(cb.Function + "_layers.zip")
-			CheckError(err)
-			defer outFile.Close()
-			// Create a new zip archive.
-			writer := zip.NewWriter(outFile)
-			// Add some files to the archive.
-			AddFiles(writer, layerFolder, "python/")
-
-			// Make sure to check the error on Close.
-			err = writer.Close()
+			fmt.Println("Building layer packages " + builder.Describe() + ".")
+			err = builder.Build(cb, requirements, target)
 			if err != nil {
+				os.RemoveAll(buildFolder)
 				fmt.Println(err)
-			} else {
-				fmt.Println("ZIP archive is ready. The name of the archive is " + cb.Function + "_layers.zip")
+				os.Exit(1)
 			}
 
-			// check --now flag to publish layer
-			if Now {
-				// Publish layer leveraging shared config to pickup region configuration
-				sess := session.Must(session.NewSessionWithOptions(session.Options{
-					SharedConfigState: session.SharedConfigEnable,
-				}))
-
-				svc := lambda.New(sess)
+			// Collect the files of the archive
+			archive := NewArchive()
+			err = archive.AddDir(layerFolder, "python")
+			CheckError(err)
+			err = trimArchive(os.Stdout, archive, ".", cb)
+			CheckError(err)
+			_, err = archive.Write(layerZip)
+			CheckError(err)
+			os.RemoveAll(buildFolder)
 
-				contents, err := ioutil.ReadFile(cb.Function + "_layers.zip")
-				CheckError(err)
+			// keep the archive for the next build
+			if cachePath != "" {
+				if err := copyFile(layerZip, cachePath); err != nil {
+					fmt.Println("Could not cache the archive: " + err.Error())
+				}
+			}
+		}
+		if requirements == exportedRequirements {
+			os.Remove(exportedRequirements)
+		}
+		contents, err := ioutil.ReadFile(layerZip)
+		CheckError(err)
+		fmt.Println("ZIP archive is ready. The name of the archive is " + layerZip)
+		fmt.Println("SHA-256: " + codeSha256(contents))
+
+		// check --now flag to publish layer
+		if Now {
+			// Publish layer leveraging shared config to pickup region configuration
+			sess := session.Must(session.NewSessionWithOptions(session.Options{
+				SharedConfigState: session.SharedConfigEnable,
+			}))
+
+			svc := lambda.New(sess, endpointConfig("lambda"))
+
+			// reuse a version published from the same build
+			var existing *lambda.LayerVersionsListItem
+			if !noCacheFlag {
+				existing, err = findLayerVersion(svc, cb.Layer, key)
+				if CheckAWSError(err) {
+					os.Exit(1)
+				}
+			}
 
-				input := &lambda.PublishLayerVersionInput{
-					CompatibleRuntimes: []*string{
-						aws.String("python3.8"),
-					},
-					Content: &lambda.LayerVersionContentInput{
-						ZipFile: contents,
-					},
-					Description: &cb.Description,
-					LayerName:   &cb.Layer,
-					//				LicenseInfo: aws.String("MIT"),
+			input := &lambda.PublishLayerVersionInput{
+				CompatibleRuntimes: aws.StringSlice(cb.LayerRuntimes()),
+				Description:        aws.String(layerDescription(cb.Description, key)),
+				LayerName:          &cb.Layer,
+				//				LicenseInfo: aws.String("MIT"),
+			}
+			if architectures := cb.LayerArchitectures(); len(architectures) > 0 {
+				input.CompatibleArchitectures = aws.StringSlice(architectures)
+			}
+			var layerVersionARN string
+			if existing != nil {
+				fmt.Printf("Version %d of %s was published from the same requirements, reusing it.\n", aws.Int64Value(existing.Version), cb.Layer)
+				layerVersionARN = aws.StringValue(existing.LayerVersionArn)
+			} else {
+				content, err := stageArchive(os.Stdout, cb, cb.Layer, contents)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
 				}
+				input.Content = content.layerContent()
+
 				// getting the result of the publish layer operation
 				result, err := svc.PublishLayerVersion(input)
 				if CheckAWSError(err) {
 					os.Exit(1)
 				}
-				//fmt.Println(result)
-				// we want to check if we are going to add the layer to our Lambda function
-				fmt.Println("Layer built. Do you want to add it to your Lambda function, " + cb.Function + "? [yes/no]")
-				userInput := bufio.NewScanner(os.Stdin)
-				userInput.Scan()
-				//				fmt.Printf("%v", input.Text())
-				if userInput.Text() != "yes" && userInput.Text() != "no" {
-					fmt.Println("Please type either 'yes' or 'no'.")
-					os.Exit(1)
+				layerVersionARN = aws.StringValue(result.LayerVersionArn)
+			}
+			// we want to check if we are going to add the layer to our Lambda function
+			fmt.Println("Layer built. Do you want to add it to your Lambda function" + pluralize(len(functionNames)) + ", " + strings.Join(functionNames, ", ") + "? [yes/no]")
+			userInput := bufio.NewScanner(os.Stdin)
+			userInput.Scan()
+			//				fmt.Printf("%v", input.Text())
+			if userInput.Text() != "yes" && userInput.Text() != "no" {
+				fmt.Println("Please type either 'yes' or 'no'.")
+				os.Exit(1)
+			}
+			if userInput.Text() == "no" {
+				fmt.Println("Understood. The new layer version is not added to the Lambda function.")
+				os.Exit(1)
+			} else {
+				fmt.Println("Understood. Adding new layer...")
+				fmt.Println("=========")
+				fmt.Println(layerVersionARN)
+
+				// the new version is known locally, the other layers are sized from AWS
+				sizes := layerSizes{}
+				sizes[layerVersionARN], err = unzippedSize(contents)
+				CheckError(err)
+
+				// work out and check the layers of every function before changing any
+				functionLayers := map[string][]string{}
+				for _, fn := range cb.All() {
+					if err := fn.RequireARN(); err != nil {
+						fmt.Println(fn.Function + ": " + err.Error())
+						os.Exit(1)
+					}
+					config, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+						FunctionName: aws.String(fn.Function),
+					})
+					if CheckAWSError(err) {
+						os.Exit(1)
+					}
+					layers := mergeLayers(config.Layers, layerVersionARN)
+					if err := checkLayerLimits(svc, fn.Function, layers, sizes); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+					functionLayers[fn.Function] = layers
 				}
-				if userInput.Text() == "no" {
-					fmt.Println("Understood. The new layer version is not added to the Lambda function.")
-					os.Exit(1)
-				} else {
-					fmt.Println("Understood. Adding new layer...")
-					// retrieving the LayerVersionArn to use when adding the layer to our Lambda function
-					layerVersionARN := *result.LayerVersionArn
-					layerVersionARNSlice := []string{layerVersionARN}
-					fmt.Println("=========")
-					fmt.Println(layerVersionARN)
+
+				for _, fn := range cb.All() {
+					// an update still in progress would reject ours
+					if err := waitForChange(os.Stdout, svc, fn.Function); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
 
 					input := &lambda.UpdateFunctionConfigurationInput{
-						FunctionName: &cb.Function,
-						Layers:       aws.StringSlice(layerVersionARNSlice),
+						FunctionName: aws.String(fn.Function),
+						Layers:       aws.StringSlice(functionLayers[fn.Function]),
 					}
 
 					result, err := svc.UpdateFunctionConfiguration(input)
@@ -234,7 +252,11 @@ var cookLayerCmd = &cobra.Command{
 						os.Exit(1)
 					}
 
-					fmt.Println(result)
+					fmt.Println(redact(result.String()))
+					if err := waitForFunction(os.Stdout, svc, fn.Function); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
 				}
 			}
 		}
@@ -243,4 +265,8 @@ var cookLayerCmd = &cobra.Command{
 
 func init() {
 	cookLayerCmd.PersistentFlags().BoolVar(&Now, "now", false, "Cook and deliver layer.")
+	cookLayerCmd.PersistentFlags().StringVar(&builderFlag, "builder", "", "build the layer packages in a container or with the local pip [container|pip].")
+	cookLayerCmd.PersistentFlags().StringVar(&engineFlag, "engine", "", "container engine of the container builder [docker|podman|nerdctl].")
+	cookLayerCmd.PersistentFlags().BoolVar(&noCacheFlag, "no-cache", false, "rebuild and republish the layer even when the requirements did not change.")
+	cookLayerCmd.PersistentFlags().StringVar(&wheelhouseFlag, "wheelhouse", "", "folder of wheels to install from offline with the pip builder.")
 }
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var (
+	pythonDefPattern    = regexp.MustCompile(`^(async\s+)?def\s+([A-Za-z_]\w*)\s*\(`)
+	pythonAssignPattern = regexp.MustCompile(`^([A-Za-z_]\w*)\s*(:[^=]*)?=[^=]`)
+	pythonImportPattern = regexp.MustCompile(`^(from\s+[\w.]+\s+)?import\s+`)
+	pythonScopePattern  = regexp.MustCompile(`^((async\s+)?def|class)\s`)
+)
+
+// Function to check, without a Python interpreter, that the module at path
+// defines the handler function at module level with an (event, context)
+// shaped signature. Definitions inside compound statements such as try or
+// if count, those inside functions and classes do not. A module-level
+// assignment or import of the name is accepted as is, since its signature
+// cannot be known without running the module.
+func checkHandler(path, function string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	source := string(data)
+
+	for _, stmt := range moduleStatements(source) {
+		if m := pythonDefPattern.FindStringSubmatch(stmt); m != nil && m[2] == function {
+			if m[1] != "" {
+				// the runtime calls the handler without awaiting it
+				return fmt.Errorf("handler %s in %s is async, the Lambda Python runtime cannot run coroutines", function, path)
+			}
+			params, ok := closingParen(stmt[len(m[0]):])
+			if !ok {
+				return fmt.Errorf("could not read the parameters of %s in %s", function, path)
+			}
+			return checkHandlerParams(function, params)
+		}
+		if m := pythonAssignPattern.FindStringSubmatch(stmt); m != nil && m[1] == function {
+			return nil
+		}
+		if m := pythonImportPattern.FindString(stmt); m != "" && importsName(stmt[len(m):], function) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s does not define a top-level function %s", path, function)
+}
+
+// Function to check whether the names of an import statement, e.g.
+// "(handler, other as alias)" or "package.module as handler", bind name.
+// A star import may bind any name.
+func importsName(names, name string) bool {
+	names = strings.Trim(strings.TrimSpace(names), "()")
+	for _, item := range splitTopLevel(names, ',') {
+		fields := strings.Fields(item)
+		switch {
+		case len(fields) == 1 && fields[0] == "*":
+			return true
+		case len(fields) == 1:
+			// import a.b binds a
+			if strings.Split(fields[0], ".")[0] == name {
+				return true
+			}
+		case len(fields) == 3 && fields[1] == "as":
+			if fields[2] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Function to check that a handler can be called with (event, context).
+func checkHandlerParams(function, params string) error {
+	positional, required, varArgs := 0, 0, false
+	for _, p := range splitTopLevel(params, ',') {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "" || p == "/":
+			continue
+		case p == "*":
+			// keyword-only parameters follow
+			goto done
+		case strings.HasPrefix(p, "**"):
+			continue
+		case strings.HasPrefix(p, "*"):
+			varArgs = true
+			goto done
+		}
+		positional++
+		if len(splitTopLevel(p, '=')) == 1 {
+			required++
+		}
+	}
+done:
+	if required > 2 || (positional < 2 && !varArgs) {
+		return fmt.Errorf("handler %s(%s) cannot be called with (event, context)", function, strings.Join(strings.Fields(params), " "))
+	}
+	return nil
+}
+
+// pythonStatement is a logical line of Python source and its indentation.
+type pythonStatement struct {
+	indent int
+	text   string
+}
+
+// Function to return the statements run when a module is imported: the
+// top-level ones and those in the bodies of compound statements, but not
+// the bodies of functions and classes.
+func moduleStatements(source string) []string {
+	var stmts []string
+	skip := -1
+	for _, stmt := range pythonStatements(source) {
+		if skip >= 0 && stmt.indent > skip {
+			continue
+		}
+		skip = -1
+		if pythonScopePattern.MatchString(stmt.text) {
+			skip = stmt.indent
+		}
+		stmts = append(stmts, stmt.text)
+	}
+	return stmts
+}
+
+// Function to split Python source into its logical lines, skipping comments
+// and joining lines continued in brackets, strings or with a backslash.
+func pythonStatements(source string) []pythonStatement {
+	var stmts []pythonStatement
+	var cur strings.Builder
+	depth := 0
+	quote := ""
+	lineStart := true
+
+	flush := func() {
+		line := cur.String()
+		if text := strings.TrimLeft(line, " \t"); strings.TrimSpace(text) != "" {
+			stmts = append(stmts, pythonStatement{indent: len(line) - len(text), text: text})
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+		if quote != "" {
+			cur.WriteByte(c)
+			if c == '\\' && i+1 < len(source) {
+				i++
+				cur.WriteByte(source[i])
+			} else if strings.HasPrefix(source[i:], quote) {
+				cur.WriteString(quote[1:])
+				i += len(quote) - 1
+				quote = ""
+			} else if c == '\n' && len(quote) == 1 {
+				quote = ""
+			}
+			continue
+		}
+
+		if lineStart && depth == 0 && c != '\n' && c != '\r' {
+			flush()
+		}
+		lineStart = false
+
+		switch c {
+		case '#':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+			i--
+			continue
+		case '"', '\'':
+			quote = string(c)
+			if strings.HasPrefix(source[i:], strings.Repeat(string(c), 3)) {
+				quote = strings.Repeat(string(c), 3)
+				cur.WriteString(quote[1:])
+				i += 2
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case '\n':
+			if depth == 0 && !strings.HasSuffix(cur.String(), "\\") {
+				lineStart = true
+			}
+		}
+		cur.WriteByte(c)
+	}
+	flush()
+	return stmts
+}
+
+// Function to return the text up to the parenthesis closing an already
+// opened one.
+func closingParen(s string) (string, bool) {
+	depth := 1
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return s[:i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// Function to split s on sep, ignoring separators nested in brackets or strings.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				// keep comparison operators such as == in defaults together
+				if sep == '=' && (i+1 < len(s) && s[i+1] == '=' || i > 0 && strings.ContainsRune("=!<>", rune(s[i-1]))) {
+					continue
+				}
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
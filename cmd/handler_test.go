@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModuleStatements(t *testing.T) {
+	source := `"""Module docstring with def fake(event, context):"""
+import json  # comment (
+
+def handler(event,
+            context):
+    return {"body": "def inner(x):"}
+
+# def commented(event, context):
+VALUE = '#not a comment'
+try:
+    from fast import run
+except ImportError:  # fall back
+    class Runner:
+        def run(self):
+            pass
+
+    def run(event, context):
+        return \
+            None
+`
+	want := []string{
+		`"""Module docstring with def fake(event, context):"""`,
+		"import json",
+		"def handler(event,\n            context):",
+		"VALUE = '#not a comment'",
+		"try:",
+		"from fast import run",
+		"except ImportError:",
+		"class Runner:",
+		"def run(event, context):",
+	}
+	var got []string
+	for _, stmt := range moduleStatements(source) {
+		got = append(got, strings.TrimSpace(stmt))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("moduleStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckHandlerParams(t *testing.T) {
+	tests := []struct {
+		params string
+		ok     bool
+	}{
+		{"event, context", true},
+		{"event, context=None", true},
+		{"event, context, /", true},
+		{"self, event, context=None", true},
+		{"*args", true},
+		{"event, *args, **kwargs", true},
+		{"event, context, retries=3, *, debug=False", true},
+		{"event, default=(1, 2)", true},
+		{"event", false},
+		{"", false},
+		{"**kwargs", false},
+		{"event, *, context", false},
+		{"event, context, extra", false},
+	}
+	for _, tt := range tests {
+		err := checkHandlerParams("handler", tt.params)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkHandlerParams(%q) error = %v, want ok %v", tt.params, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckHandler(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{source: "def handler(event, context):\n    pass\n"},
+		{source: "handler = make_handler()\n"},
+		{source: "from .impl import handler\n"},
+		{source: "from app.main import (\n    other,\n    handler,\n)\n"},
+		{source: "from app.main import main as handler\n"},
+		{source: "from app.main import *\n"},
+		{source: "import handler.impl\n"},
+		{source: "from app import handler_v2\n", err: "does not define a top-level function handler"},
+		{source: "class App:\n    def handler(self, event, context):\n        pass\n", err: "does not define a top-level function handler"},
+		{source: "try:\n    from x import handler\nexcept ImportError:\n    handler = None\n"},
+		{source: "if True:\n    def handler(event, context):\n        pass\n"},
+		{source: "def handler(event):\n    pass\n", err: "handler(event) cannot be called with (event, context)"},
+		{source: "async def handler(event, context):\n    pass\n", err: "is async"},
+		{source: "def make():\n    def handler(event, context):\n        pass\n", err: "does not define a top-level function handler"},
+	}
+	path := filepath.Join(t.TempDir(), "orders.py")
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(path, []byte(tt.source), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := checkHandler(path, "handler")
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("checkHandler(%q) error = %v, want %q", tt.source, err, tt.err)
+		}
+	}
+}
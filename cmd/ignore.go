@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"chefcli/recipe"
+)
+
+// ignoreFile is read from the folder being packaged to trim archives.
+const ignoreFile = ".chefignore"
+
+// ignoreRule is a single gitignore-style pattern.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher decides which archive entries to leave out, following
+// gitignore semantics: later rules win, "!" re-includes, a trailing "/"
+// only matches folders and a pattern with a "/" is anchored to the root of
+// the archive. Include rules from the recipe win over every other rule,
+// even for files inside an ignored folder.
+type ignoreMatcher struct {
+	rules   []ignoreRule
+	include []ignoreRule
+}
+
+// sourceIgnore lists what is never packaged from source folders: version
+// control, byte code, archives and the recipe itself.
+var sourceIgnore = []string{".git/", "__pycache__/", "*.pyc", "*.zip", ignoreFile, "recipe.yml", "recipe.yaml", exportedRequirements, layerBuildPrefix + "*/"}
+
+// Function to build the skip rule for source folders below root. Virtual
+// environments are only packaged through --venv.
+func sourceSkip(root string) func(string, bool) bool {
+	m := &ignoreMatcher{}
+	for _, pattern := range sourceIgnore {
+		// the built-in patterns are known to be valid
+		m.add(pattern)
+	}
+	return func(rel string, isDir bool) bool {
+		if isDir && FileExists(filepath.Join(root, rel, "pyvenv.cfg")) {
+			return true
+		}
+		return m.matches(rel, isDir)
+	}
+}
+
+// Function to build a matcher from a .chefignore file, when present, and the
+// recipe exclude and include globs. A bad pattern is reported with its line
+// or recipe field.
+func loadIgnore(path string, exclude, include []string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if err := m.add(scanner.Text()); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range exclude {
+		if err := m.add(pattern); err != nil {
+			return nil, fmt.Errorf("exclude: %v", err)
+		}
+	}
+	for _, pattern := range include {
+		rule, ok, err := parseRule(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include: %v", err)
+		}
+		if ok {
+			m.include = append(m.include, rule)
+		}
+	}
+	return m, nil
+}
+
+// Function to add a .chefignore line to the rules.
+func (m *ignoreMatcher) add(line string) error {
+	rule, ok, err := parseRule(line)
+	if ok {
+		m.rules = append(m.rules, rule)
+	}
+	return err
+}
+
+// Function to parse a .chefignore line. Blank lines and comments give no
+// rule.
+func parseRule(line string) (ignoreRule, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+	pattern := line
+	rule := ignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false, nil
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	rule.re = re
+	return rule, true, nil
+}
+
+// Function to convert a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 0 {
+				class := glob[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(`\[`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// matches returns whether the last rule matching path ignores it.
+func (m *ignoreMatcher) matches(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(path) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Ignored returns whether the archive entry name is left out. As with git,
+// a file inside an ignored folder cannot be re-included with "!", only with
+// a recipe include.
+func (m *ignoreMatcher) Ignored(name string) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	for i := 1; i <= len(parts); i++ {
+		for _, rule := range m.include {
+			isDir := i < len(parts)
+			if (isDir || !rule.dirOnly) && rule.re.MatchString(strings.Join(parts[:i], "/")) {
+				return false
+			}
+		}
+	}
+	for i := 1; i < len(parts); i++ {
+		if m.matches(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.matches(name, false)
+}
+
+// Function to trim an archive with the .chefignore file found in dir and the
+// recipe globs, printing what was left out.
+func trimArchive(out io.Writer, archive *Archive, dir string, cb *recipe.Cookbook) error {
+	m, err := loadIgnore(filepath.Join(dir, ignoreFile), cb.Exclude, cb.Include)
+	if err != nil {
+		return err
+	}
+	files, size, err := archive.Trim(m)
+	if err != nil {
+		return err
+	}
+	if files > 0 {
+		fmt.Fprintf(out, "Trimmed %d file%v (%d bytes) from the archive.\n", files, pluralize(files), size)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	chefignore := "# tests are not deployed\ntests/\n*.md\n!README.md\n/build\ndocs/**/*.png\nboto3/\n"
+	include := []string{"boto3/session.py", "CHANGES.md"}
+	dir := t.TempDir()
+	path := filepath.Join(dir, ignoreFile)
+	if err := ioutil.WriteFile(path, []byte(chefignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadIgnore(path, []string{"*.txt"}, include)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"handler.py":                 false,
+		"tests/test_handler.py":      true,
+		"app/tests/conftest.py":      true,
+		"tests.py":                   false,
+		"NOTES.md":                   true,
+		"README.md":                  false,
+		"CHANGES.md":                 false,
+		"build":                      true,
+		"app/build":                  false,
+		"docs/img/logo.png":          true,
+		"docs/logo.png":              true,
+		"requirements.txt":           true,
+		"boto3/client.py":            true,
+		"boto3/session.py":           false,
+		"boto3/resources/factory.py": true,
+	}
+	for name, want := range tests {
+		if got := m.Ignored(name); got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLoadIgnoreBadPattern(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ignoreFile)
+	tests := []struct {
+		chefignore string
+		exclude    []string
+		include    []string
+		err        string
+	}{
+		{chefignore: "*.md\n[z-a]\n", err: ignoreFile + `:2: invalid pattern "[z-a]"`},
+		{chefignore: `[\]`, err: ignoreFile + `:1: invalid pattern "[\\]"`},
+		{exclude: []string{"[]"}, err: `exclude: invalid pattern "[]"`},
+		{include: []string{"[z-a].py"}, err: `include: invalid pattern "[z-a].py"`},
+	}
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(path, []byte(tt.chefignore), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadIgnore(path, tt.exclude, tt.include)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.err) {
+			t.Errorf("loadIgnore() error = %v, want it to end with %q", err, tt.err)
+		}
+	}
+}
+
+func TestLoadIgnoreMissingFile(t *testing.T) {
+	m, err := loadIgnore(filepath.Join(t.TempDir(), ignoreFile), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Ignored("handler.py") {
+		t.Error("Ignored() without rules = true, want false")
+	}
+}
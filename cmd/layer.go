@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keepFlag   int
+	dryRunFlag bool
+)
+
+var layerCmd = &cobra.Command{
+	Use:   "layer",
+	Short: "Manage your Lambda Layers",
+	Long:  "Manage the published versions of the layer in your recipe.",
+	ValidArgs: []string{
+		"prune",
+	},
+	Args: cobra.OnlyValidArgs,
+}
+
+var layerPruneCmd = &cobra.Command{
+	Use:     "prune",
+	Short:   "Delete old layer versions",
+	Long:    "Delete the versions of the recipe layer older than the newest --keep versions. Versions still used by a function or function version in the account and region are kept. The versions to delete are listed before asking for confirmation.",
+	Example: "chefcli layer prune --keep 3\nchefcli layer prune --keep 3 --dry-run",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if keepFlag < 1 {
+			fmt.Println("--keep must be at least 1.")
+			os.Exit(1)
+		}
+
+		cb := LoadRecipe()
+		if err := cb.RequireLayer(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		svc := lambda.New(newSession(), endpointConfig("lambda"))
+
+		// list the published versions, newest first
+		var versions []*lambda.LayerVersionsListItem
+		err := svc.ListLayerVersionsPages(&lambda.ListLayerVersionsInput{
+			LayerName: aws.String(cb.Layer),
+		}, func(page *lambda.ListLayerVersionsOutput, lastPage bool) bool {
+			versions = append(versions, page.LayerVersions...)
+			return true
+		})
+		if CheckAWSError(err) {
+			os.Exit(1)
+		}
+		if len(versions) == 0 {
+			fmt.Println("No versions of " + cb.Layer + " are published.")
+			return
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return aws.Int64Value(versions[i].Version) > aws.Int64Value(versions[j].Version)
+		})
+
+		users, err := layerUsers(svc)
+		if CheckAWSError(err) {
+			os.Exit(1)
+		}
+
+		// decide what happens to every version
+		var prune []*lambda.LayerVersionsListItem
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tCREATED\tACTION\tUSED BY")
+		for i, v := range versions {
+			arn := aws.StringValue(v.LayerVersionArn)
+			action := "delete"
+			switch {
+			case i < keepFlag:
+				action = "keep (newest)"
+			case len(users[arn]) > 0:
+				action = "keep (in use)"
+			default:
+				prune = append(prune, v)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", aws.Int64Value(v.Version), aws.StringValue(v.CreatedDate), action, strings.Join(users[arn], ", "))
+		}
+		w.Flush()
+
+		if len(prune) == 0 {
+			fmt.Println("Nothing to prune.")
+			return
+		}
+		if dryRunFlag {
+			fmt.Printf("Dry run, %d version%v of %s would be deleted.\n", len(prune), pluralize(len(prune)), cb.Layer)
+			return
+		}
+
+		fmt.Printf("Delete %d version%v of %s? [yes/no]\n", len(prune), pluralize(len(prune)), cb.Layer)
+		userInput := bufio.NewScanner(os.Stdin)
+		userInput.Scan()
+		if userInput.Text() != "yes" && userInput.Text() != "no" {
+			fmt.Println("Please type either 'yes' or 'no'.")
+			os.Exit(1)
+		}
+		if userInput.Text() == "no" {
+			fmt.Println("Understood. No layer versions are deleted.")
+			return
+		}
+
+		for _, v := range prune {
+			_, err := svc.DeleteLayerVersion(&lambda.DeleteLayerVersionInput{
+				LayerName:     aws.String(cb.Layer),
+				VersionNumber: v.Version,
+			})
+			if CheckAWSError(err) {
+				os.Exit(1)
+			}
+			fmt.Printf("Deleted version %d.\n", aws.Int64Value(v.Version))
+		}
+	},
+}
+
+// Function to map every layer version ARN in the account and region to the
+// functions using it. Published function versions count, as they keep
+// their layers for good.
+func layerUsers(svc *lambda.Lambda) (map[string][]string, error) {
+	users := map[string][]string{}
+	err := svc.ListFunctionsPages(&lambda.ListFunctionsInput{
+		FunctionVersion: aws.String(lambda.FunctionVersionAll),
+	}, func(page *lambda.ListFunctionsOutput, lastPage bool) bool {
+		for _, fn := range page.Functions {
+			name := aws.StringValue(fn.FunctionName)
+			if version := aws.StringValue(fn.Version); version != "" && version != "$LATEST" {
+				name += ":" + version
+			}
+			for _, l := range fn.Layers {
+				arn := aws.StringValue(l.Arn)
+				users[arn] = append(users[arn], name)
+			}
+		}
+		return true
+	})
+	return users, err
+}
+
+func init() {
+	layerCmd.PersistentFlags().StringVar(&recipeFlag, "recipe", "", "path to the recipe file (defaults to recipe.yml or recipe.yaml).")
+	layerCmd.PersistentFlags().StringVar(&stageFlag, "stage", "", "recipe stage to overlay on the base recipe, e.g. prod.")
+	layerPruneCmd.Flags().IntVar(&keepFlag, "keep", 3, "number of newest versions to keep.")
+	layerPruneCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "only list the versions that would be deleted.")
+
+	layerCmd.AddCommand(layerPruneCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// layerBuildPrefix names the temporary folders layers are built in.
+const layerBuildPrefix = ".chefcli-layer-"
+
+// layerTagPrefix marks the build key in the description of published layer
+// versions.
+const layerTagPrefix = "chefcli:"
+
+// Function to compute the key of a layer build from everything that changes
+// the archive or the published version: the builder, the requirements, the
+// compatible runtimes and architectures, the platform and the trimming rules.
+func layerCacheKey(cb *recipe.Cookbook, builder, requirements string) (string, error) {
+	hash := sha256.New()
+	for i, path := range []string{requirements, ignoreFile} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		io.WriteString(hash, strconv.Itoa(i)+"\x00")
+		hash.Write(data)
+		io.WriteString(hash, "\x00")
+	}
+	for _, part := range [][]string{{builder}, cb.LayerRuntimes(), cb.LayerArchitectures(), {lambdaPlatform(cb)}, cb.Exclude, cb.Include} {
+		io.WriteString(hash, strings.Join(part, "\x01")+"\x00")
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Function to get the path of the cached archive of a layer build. The
+// cache lives in the user cache folder, e.g. ~/.cache/chefcli/layers.
+func layerCachePath(key string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chefcli", "layers", key+".zip"), nil
+}
+
+// Function to copy a file, creating the destination folder.
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Function to record the build key in a layer description, keeping within
+// the 256 characters Lambda allows.
+func layerDescription(description, key string) string {
+	tag := "[" + layerTagPrefix + key + "]"
+	if max := 256 - len(tag) - 1; len(description) > max {
+		description = description[:max]
+	}
+	return strings.TrimSpace(description + " " + tag)
+}
+
+// Function to find a published version of the layer built with the same key.
+func findLayerVersion(svc *lambda.Lambda, layer, key string) (*lambda.LayerVersionsListItem, error) {
+	var found *lambda.LayerVersionsListItem
+	tag := "[" + layerTagPrefix + key + "]"
+	err := svc.ListLayerVersionsPages(&lambda.ListLayerVersionsInput{
+		LayerName: aws.String(layer),
+	}, func(page *lambda.ListLayerVersionsOutput, lastPage bool) bool {
+		for _, v := range page.LayerVersions {
+			if strings.Contains(aws.StringValue(v.Description), tag) {
+				found = v
+				return false
+			}
+		}
+		return true
+	})
+	return found, err
+}
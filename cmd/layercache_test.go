@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chefcli/recipe"
+)
+
+func TestLayerCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	requirements := filepath.Join(dir, "requirements.txt")
+	if err := ioutil.WriteFile(requirements, []byte("requests==2.31.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keep a .chefignore of the package folder out of the key
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	key := func(cb recipe.Cookbook, builder string) string {
+		cb.Runtime = "python3.12"
+		k, err := layerCacheKey(&cb, builder, requirements)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key(recipe.Cookbook{}, "pip")
+	if again := key(recipe.Cookbook{}, "pip"); again != base {
+		t.Errorf("layerCacheKey() = %s then %s for the same build", base, again)
+	}
+	changes := map[string]string{
+		"builder":                  key(recipe.Cookbook{}, "container"),
+		"compatible runtimes":      key(recipe.Cookbook{CompatibleRuntimes: []string{"python3.11", "python3.12"}}, "pip"),
+		"compatible architectures": key(recipe.Cookbook{CompatibleArchitectures: []string{"x86_64"}}, "pip"),
+		"architecture":             key(recipe.Cookbook{Architectures: []string{"arm64"}}, "pip"),
+		"exclude":                  key(recipe.Cookbook{Exclude: []string{"*.pyi"}}, "pip"),
+	}
+	for change, k := range changes {
+		if k == base {
+			t.Errorf("layerCacheKey() did not change with the %s", change)
+		}
+	}
+
+	if err := ioutil.WriteFile(requirements, []byte("requests==2.32.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if k := key(recipe.Cookbook{}, "pip"); k == base {
+		t.Error("layerCacheKey() did not change with the requirements")
+	}
+}
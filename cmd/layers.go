@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Lambda limits on the layers of a function.
+const (
+	maxLayers        = 5
+	maxUnzippedBytes = 250 * 1024 * 1024
+)
+
+// Function to get the ARN of a layer without its version, e.g.
+// arn:aws:lambda:eu-west-1:123456789012:layer:deps from
+// arn:aws:lambda:eu-west-1:123456789012:layer:deps:7.
+func layerBaseARN(arn string) string {
+	if i := strings.LastIndex(arn, ":"); i > 0 && strings.Count(arn, ":") > 6 {
+		return arn[:i]
+	}
+	return arn
+}
+
+// Function to put a layer version into the layers of a function. A version
+// of the same layer is replaced in place, otherwise the layer is appended.
+func mergeLayers(current []*lambda.Layer, arn string) []string {
+	var layers []string
+	replaced := false
+	for _, l := range current {
+		existing := aws.StringValue(l.Arn)
+		if layerBaseARN(existing) == layerBaseARN(arn) {
+			if replaced {
+				continue
+			}
+			existing, replaced = arn, true
+		}
+		layers = append(layers, existing)
+	}
+	if !replaced {
+		layers = append(layers, arn)
+	}
+	return layers
+}
+
+// Function to sum the unzipped size of the files in an archive.
+func unzippedSize(contents []byte) (int64, error) {
+	r, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, f := range r.File {
+		size += int64(f.UncompressedSize64)
+	}
+	return size, nil
+}
+
+// Function to download an archive from the presigned location Lambda returns.
+func downloadArchive(location string) ([]byte, error) {
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading the archive failed with %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Function to download an archive and sum its unzipped size.
+func remoteUnzippedSize(location string) (int64, error) {
+	contents, err := downloadArchive(location)
+	if err != nil {
+		return 0, err
+	}
+	return unzippedSize(contents)
+}
+
+// layerSizes keeps the unzipped size of layer versions, so that layers
+// shared by several functions are only downloaded once.
+type layerSizes map[string]int64
+
+// Function to get the unzipped size of a layer version.
+func (s layerSizes) get(svc *lambda.Lambda, arn string) (int64, error) {
+	if size, ok := s[arn]; ok {
+		return size, nil
+	}
+	layer, err := svc.GetLayerVersionByArn(&lambda.GetLayerVersionByArnInput{Arn: aws.String(arn)})
+	if err != nil {
+		return 0, err
+	}
+	size, err := remoteUnzippedSize(aws.StringValue(layer.Content.Location))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", arn, err)
+	}
+	s[arn] = size
+	return size, nil
+}
+
+// Function to check that a function and its layers stay within the Lambda
+// limits of 5 layers and 250 MB unzipped.
+func checkLayerLimits(svc *lambda.Lambda, function string, layers []string, sizes layerSizes) error {
+	if len(layers) > maxLayers {
+		return fmt.Errorf("%s would use %d layers, Lambda allows at most %d", function, len(layers), maxLayers)
+	}
+	code, err := svc.GetFunction(&lambda.GetFunctionInput{FunctionName: aws.String(function)})
+	if err != nil {
+		return err
+	}
+	total, err := remoteUnzippedSize(aws.StringValue(code.Code.Location))
+	if err != nil {
+		return fmt.Errorf("%s: %v", function, err)
+	}
+	for _, arn := range layers {
+		size, err := sizes.get(svc, arn)
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+	if total > maxUnzippedBytes {
+		return fmt.Errorf("%s and its layers would be %.1f MB unzipped, Lambda allows at most %d MB", function, float64(total)/(1024*1024), maxUnzippedBytes/(1024*1024))
+	}
+	return nil
+}
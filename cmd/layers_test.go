@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestMergeLayers(t *testing.T) {
+	const (
+		deps5  = "arn:aws:lambda:eu-west-1:123456789012:layer:deps:5"
+		deps6  = "arn:aws:lambda:eu-west-1:123456789012:layer:deps:6"
+		other  = "arn:aws:lambda:eu-west-1:123456789012:layer:other:2"
+		shared = "arn:aws:lambda:eu-west-1:210987654321:layer:deps:1"
+	)
+	layers := func(arns ...string) []*lambda.Layer {
+		var l []*lambda.Layer
+		for _, arn := range arns {
+			l = append(l, &lambda.Layer{Arn: aws.String(arn)})
+		}
+		return l
+	}
+	tests := []struct {
+		name    string
+		current []*lambda.Layer
+		want    []string
+	}{
+		{"no layers", nil, []string{deps6}},
+		{"appends a new layer", layers(other), []string{other, deps6}},
+		{"replaces in place", layers(deps5, other), []string{deps6, other}},
+		{"keeps the version", layers(other, deps6), []string{other, deps6}},
+		{"drops duplicates", layers(deps5, other, deps5), []string{deps6, other}},
+		{"other account", layers(shared), []string{shared, deps6}},
+	}
+	for _, tt := range tests {
+		if got := mergeLayers(tt.current, deps6); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeLayers() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLayerBaseARN(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:lambda:eu-west-1:123456789012:layer:deps:7": "arn:aws:lambda:eu-west-1:123456789012:layer:deps",
+		"arn:aws:lambda:eu-west-1:123456789012:layer:deps":   "arn:aws:lambda:eu-west-1:123456789012:layer:deps",
+	}
+	for arn, want := range tests {
+		if got := layerBaseARN(arn); got != want {
+			t.Errorf("layerBaseARN(%q) = %q, want %q", arn, got, want)
+		}
+	}
+}
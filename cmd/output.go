@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secrets holds the values resolved from SSM and Secrets Manager. They are
+// masked in everything printed through redact or a prefixWriter.
+var secrets struct {
+	sync.Mutex
+	values []string
+}
+
+// Function to register values that must never be printed.
+func addSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range values {
+		if v != "" {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+	// mask longer values first so a secret containing another is fully masked
+	sort.Slice(secrets.values, func(i, j int) bool {
+		return len(secrets.values[i]) > len(secrets.values[j])
+	})
+}
+
+// Function to mask the registered secrets in s.
+func redact(s string) string {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, "********")
+	}
+	return s
+}
+
+// prefixWriter prefixes every line written to it, so that the output of
+// functions cooked in parallel stays readable. Writers sharing a mutex never
+// interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{mu: mu, w: w, prefix: prefix}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	i := bytes.LastIndexByte(p.buf, '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	// redact before splitting, a secret such as a PEM key spans lines
+	text := redact(string(p.buf[:i+1]))
+	p.buf = append([]byte(nil), p.buf[i+1:]...)
+	if err := p.writeLines(text); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush writes out a trailing line that has no newline yet.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLines(redact(string(p.buf)) + "\n")
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLines(text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		if _, err := io.WriteString(p.w, p.prefix+strings.TrimSuffix(line, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
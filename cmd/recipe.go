@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"chefcli/recipe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	schemaFlag bool
+)
+
+var recipeCmd = &cobra.Command{
+	Use:   "recipe",
+	Short: "Work with your recipe",
+	Long:  `Check your recipe.yml or recipe.yaml before cooking with it.`,
+	ValidArgs: []string{
+		"validate",
+	},
+	Args: cobra.OnlyValidArgs,
+}
+
+var recipeValidateCmd = &cobra.Command{
+	Use:     "validate [recipe]",
+	Short:   "Validate your recipe",
+	Long:    "Validate your recipe and report every unknown field, invalid value and missing field with its line number. With --schema, print the JSON Schema of recipes for editor integration instead.",
+	Example: "chefcli recipe validate\nchefcli recipe validate --schema > recipe.schema.json",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if schemaFlag {
+			schema, err := recipe.Schema()
+			CheckError(err)
+			fmt.Println(string(schema))
+			return
+		}
+
+		path := ""
+		if len(args) == 1 {
+			path = args[0]
+		}
+		path, err := recipe.Find(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		data, err := ioutil.ReadFile(path)
+		CheckError(err)
+
+		problems := recipe.Check(data)
+		if len(problems) == 0 {
+			fmt.Println(path + " is valid.")
+			return
+		}
+		fmt.Printf("Found %d problem%v in %s:\n", len(problems), pluralize(len(problems)), path)
+		for _, p := range problems {
+			fmt.Println("  " + p.String())
+		}
+		os.Exit(1)
+	},
+}
+
+func init() {
+	recipeValidateCmd.Flags().BoolVar(&schemaFlag, "schema", false, "print the JSON Schema of recipes.")
+
+	recipeCmd.AddCommand(recipeValidateCmd)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"chefcli/recipe"
+)
+
+// exportedRequirements is the pinned requirements file exported from a
+// lockfile for the layer build. It is written to the current folder so the
+// container builder sees it.
+const exportedRequirements = ".chefcli-requirements.txt"
+
+// dependencyFiles are the files each dependency source is read from, in the
+// order they are looked for when the recipe does not choose one.
+var dependencyFiles = []struct {
+	source string
+	file   string
+}{
+	{"requirements", "requirements.txt"},
+	{"poetry", "poetry.lock"},
+	{"pipenv", "Pipfile.lock"},
+	{"uv", "uv.lock"},
+	{"pyproject", "pyproject.toml"},
+}
+
+// Function to find where the layer packages are listed, preferring the
+// source chosen in the recipe.
+func dependencySource(cb *recipe.Cookbook) (string, string, error) {
+	var files []string
+	for _, d := range dependencyFiles {
+		files = append(files, d.file)
+		if cb.Dependencies != "" && cb.Dependencies != d.source {
+			continue
+		}
+		if FileExists(d.file) {
+			return d.source, d.file, nil
+		}
+		if cb.Dependencies != "" {
+			return "", "", fmt.Errorf("the recipe lists its dependencies with %s, but there is no %s file present", d.source, d.file)
+		}
+	}
+	return "", "", fmt.Errorf("there is no %s file present. Please set one up with the required packages for your layer", strings.Join(files, ", "))
+}
+
+// Function to get a pinned requirements file for the layer build, exporting
+// it from the lockfile when the packages are not listed in requirements.txt.
+func layerRequirements(cb *recipe.Cookbook) (string, error) {
+	source, file, err := dependencySource(cb)
+	if err != nil {
+		return "", err
+	}
+	switch source {
+	case "requirements":
+		return file, nil
+	case "poetry":
+		err = runExport("poetry", "export", "--format", "requirements.txt", "--without-hashes", "--output", exportedRequirements)
+	case "pipenv":
+		err = exportPipfileLock(file, exportedRequirements)
+	case "uv":
+		err = runExport("uv", "export", "--frozen", "--format", "requirements-txt", "--no-hashes", "--no-dev", "--no-emit-project", "--output-file", exportedRequirements)
+	case "pyproject":
+		err = compilePyproject(cb, file, exportedRequirements)
+	}
+	if err != nil {
+		return "", fmt.Errorf("exporting the requirements from %s failed: %v", file, err)
+	}
+	fmt.Println("Exported the requirements from " + file + ".")
+	return exportedRequirements, nil
+}
+
+// Function to run the tool exporting a lockfile.
+func runExport(tool string, args ...string) error {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return fmt.Errorf("%s is not on your PATH", tool)
+	}
+	output, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Function to pin the dependencies of pyproject.toml for the Lambda
+// platform with uv, or with pip-compile when uv is not installed.
+func compilePyproject(cb *recipe.Cookbook, file, dest string) error {
+	if _, err := exec.LookPath("uv"); err == nil {
+		platform := "x86_64-manylinux2014"
+		if lambdaPlatform(cb) == "manylinux2014_aarch64" {
+			platform = "aarch64-manylinux2014"
+		}
+		return runExport("uv", "pip", "compile", file,
+			"--python-version", strings.TrimPrefix(layerRuntime(cb), "python"),
+			"--python-platform", platform,
+			"--no-header", "--output-file", dest)
+	}
+	if _, err := exec.LookPath("pip-compile"); err == nil {
+		return runExport("pip-compile", "--quiet", "--no-header", "--output-file", dest, file)
+	}
+	return fmt.Errorf("pinning pyproject.toml needs uv or pip-compile on your PATH")
+}
+
+// pipfileLock is the part of Pipfile.lock listing the default packages.
+type pipfileLock struct {
+	Default map[string]struct {
+		Version string   `json:"version"`
+		Extras  []string `json:"extras"`
+		Markers string   `json:"markers"`
+		Git     string   `json:"git"`
+		Ref     string   `json:"ref"`
+	} `json:"default"`
+}
+
+// Function to write the default packages of a Pipfile.lock as pinned
+// requirements. Pipenv is not needed as the lockfile already pins them.
+func exportPipfileLock(file, dest string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var lock pipfileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(lock.Default))
+	for name := range lock.Default {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		p := lock.Default[name]
+		requirement := name
+		if len(p.Extras) > 0 {
+			requirement += "[" + strings.Join(p.Extras, ",") + "]"
+		}
+		switch {
+		case p.Version != "":
+			requirement += p.Version
+		case p.Git != "" && p.Ref != "":
+			requirement += " @ git+" + p.Git + "@" + p.Ref
+		default:
+			return fmt.Errorf("%s is not pinned to a version", name)
+		}
+		if p.Markers != "" {
+			requirement += "; " + p.Markers
+		}
+		lines = append(lines, requirement)
+	}
+	return ioutil.WriteFile(dest, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportPipfileLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want string
+		err  string
+	}{
+		{
+			name: "pinned packages",
+			lock: `{
+				"_meta": {"hash": {"sha256": "abc"}},
+				"default": {
+					"requests": {"version": "==2.31.0", "extras": ["socks"]},
+					"boto3": {"version": "==1.28.0", "markers": "python_version >= '3.8'"},
+					"internal": {"git": "https://example.com/internal.git", "ref": "0a1b2c"}
+				},
+				"develop": {"pytest": {"version": "==7.4.0"}}
+			}`,
+			want: "boto3==1.28.0; python_version >= '3.8'\ninternal @ git+https://example.com/internal.git@0a1b2c\nrequests[socks]==2.31.0\n",
+		},
+		{
+			name: "unpinned package",
+			lock: `{"default": {"local": {"path": "."}}}`,
+			err:  "local is not pinned to a version",
+		},
+		{
+			name: "not json",
+			lock: "[[package]]",
+			err:  "invalid character",
+		},
+	}
+	dir := t.TempDir()
+	file, dest := filepath.Join(dir, "Pipfile.lock"), filepath.Join(dir, "requirements.txt")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(file, []byte(tt.lock), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := exportPipfileLock(file, dest)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("exportPipfileLock() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("exportPipfileLock() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
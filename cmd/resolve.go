@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// awsResolver resolves ${ssm:/path} and ${secret:name} recipe references.
+// The session is only created once a reference needs it. Resolved values are
+// registered for redaction straight away, so even errors about them while
+// loading the recipe are redacted.
+type awsResolver struct {
+	sess *session.Session
+}
+
+func (r *awsResolver) Resolve(kind, name string) (string, error) {
+	if r.sess == nil {
+		r.sess = newSession()
+	}
+	switch kind {
+	case "ssm":
+		svc := ssm.New(r.sess, endpointConfig("ssm"))
+		result, err := svc.GetParameter(&ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", err
+		}
+		value := aws.StringValue(result.Parameter.Value)
+		addSecrets(value)
+		return value, nil
+	case "secret":
+		svc := secretsmanager.New(r.sess, endpointConfig("secretsmanager"))
+		result, err := svc.GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(name),
+		})
+		if err != nil {
+			return "", err
+		}
+		value := aws.StringValue(result.SecretString)
+		addSecrets(value)
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown reference kind %s", kind)
+}
+
+// Function to initialize a session that the SDK will use to load
+// credentials from the shared credentials file ~/.aws/credentials.
+func newSession() *session.Session {
+	return session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+}
+
+// Function to point a service client at a local stand-in. Setting
+// CHEFCLI_<SERVICE>_ENDPOINT, e.g. CHEFCLI_SSM_ENDPOINT=http://localhost:4566,
+// overrides the AWS endpoint of that service.
+func endpointConfig(service string) *aws.Config {
+	cfg := aws.NewConfig()
+	if endpoint := os.Getenv("CHEFCLI_" + strings.ToUpper(service) + "_ENDPOINT"); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint)
+	}
+	return cfg
+}
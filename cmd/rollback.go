@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/spf13/cobra"
+)
+
+var (
+	toFlag int
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back your Lambda functions",
+	Long: `Roll back the functions of your recipe to the previous published version, or to the version given with --to.
+When the recipe has an alias, the alias is pointed back at that version. Otherwise the code of that version is deployed again to $LATEST.`,
+	Example: "chefcli rollback --stage prod\nchefcli rollback --to 7 --function orders",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		functions := aliasFunctions(LoadRecipe())
+		if toFlag != 0 && len(functions) > 1 {
+			fmt.Println("The recipe lists several functions. Pick the one to roll back to --to with --function.")
+			os.Exit(1)
+		}
+
+		svc := lambda.New(newSession(), endpointConfig("lambda"))
+		failed := false
+		for _, fn := range functions {
+			var err error
+			if fn.Alias != "" {
+				err = rollbackAlias(os.Stdout, svc, fn, toFlag)
+			} else {
+				err = rollbackCode(os.Stdout, svc, fn, toFlag)
+			}
+			if err != nil {
+				fmt.Println(fn.Function + ": " + redact(err.Error()))
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// Function to list the published versions of a function, oldest first.
+func publishedVersions(svc *lambda.Lambda, function string) ([]*lambda.FunctionConfiguration, error) {
+	var versions []*lambda.FunctionConfiguration
+	err := svc.ListVersionsByFunctionPages(&lambda.ListVersionsByFunctionInput{
+		FunctionName: aws.String(function),
+	}, func(page *lambda.ListVersionsByFunctionOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Version) != "$LATEST" {
+				versions = append(versions, v)
+			}
+		}
+		return true
+	})
+	sort.Slice(versions, func(i, j int) bool {
+		return versionNumber(versions[i]) < versionNumber(versions[j])
+	})
+	return versions, err
+}
+
+func versionNumber(v *lambda.FunctionConfiguration) int {
+	n, _ := strconv.Atoi(aws.StringValue(v.Version))
+	return n
+}
+
+// Function to pick the version to roll back to: version to when it is
+// given, otherwise the newest version accept takes.
+func rollbackTarget(versions []*lambda.FunctionConfiguration, function string, to int, accept func(*lambda.FunctionConfiguration) bool) (*lambda.FunctionConfiguration, error) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if to != 0 && versionNumber(v) == to {
+			return v, nil
+		}
+		if to == 0 && accept(v) {
+			return v, nil
+		}
+	}
+	if to != 0 {
+		return nil, fmt.Errorf("there is no published version %d of %s", to, function)
+	}
+	return nil, fmt.Errorf("there is no earlier published version of %s to roll back to. Publish versions with cook lambda --publish", function)
+}
+
+// Function to point the recipe alias back at an earlier version. Without a
+// target, that is the newest version older than the one the alias uses, or
+// for an alias on $LATEST the newest version with different code.
+func rollbackAlias(out io.Writer, svc *lambda.Lambda, cb *recipe.Cookbook, to int) error {
+	alias, err := svc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: aws.String(cb.Function),
+		Name:         aws.String(cb.Alias),
+	})
+	if err != nil {
+		return err
+	}
+	versions, err := publishedVersions(svc, cb.Function)
+	if err != nil {
+		return err
+	}
+
+	aliasVersion := aws.StringValue(alias.FunctionVersion)
+	var accept func(*lambda.FunctionConfiguration) bool
+	if current, err := strconv.Atoi(aliasVersion); err == nil {
+		accept = func(v *lambda.FunctionConfiguration) bool {
+			return versionNumber(v) < current
+		}
+	} else if aliasVersion == "$LATEST" {
+		latest, err := svc.GetFunction(&lambda.GetFunctionInput{FunctionName: aws.String(cb.Function)})
+		if err != nil {
+			return err
+		}
+		sha := aws.StringValue(latest.Configuration.CodeSha256)
+		accept = func(v *lambda.FunctionConfiguration) bool {
+			return aws.StringValue(v.CodeSha256) != sha
+		}
+	} else {
+		return fmt.Errorf("alias %s points at version %s, which is not a published version", cb.Alias, aliasVersion)
+	}
+	target, err := rollbackTarget(versions, cb.Function, to, accept)
+	if err != nil {
+		return err
+	}
+
+	if err := pointAlias(out, svc, cb.Function, cb.Alias, aws.StringValue(target.Version)); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Rolled back %s to version %s: %s\n", cb.Function, aws.StringValue(target.Version), aws.StringValue(target.Description))
+	return nil
+}
+
+// Function to deploy the code of an earlier version to $LATEST again.
+// Without a target, that is the newest version with different code.
+func rollbackCode(out io.Writer, svc *lambda.Lambda, cb *recipe.Cookbook, to int) error {
+	latest, err := svc.GetFunction(&lambda.GetFunctionInput{FunctionName: aws.String(cb.Function)})
+	if err != nil {
+		return err
+	}
+	sha := aws.StringValue(latest.Configuration.CodeSha256)
+
+	versions, err := publishedVersions(svc, cb.Function)
+	if err != nil {
+		return err
+	}
+	target, err := rollbackTarget(versions, cb.Function, to, func(v *lambda.FunctionConfiguration) bool {
+		return aws.StringValue(v.CodeSha256) != sha
+	})
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(target.CodeSha256) == sha {
+		fmt.Fprintf(out, "%s already runs the code of version %s.\n", cb.Function, aws.StringValue(target.Version))
+		return nil
+	}
+
+	// an update still in progress would reject ours
+	if err := waitForChange(out, svc, cb.Function); err != nil {
+		return err
+	}
+
+	// fetch the archive of the version and deploy it again
+	code, err := svc.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: aws.String(cb.Function),
+		Qualifier:    target.Version,
+	})
+	if err != nil {
+		return err
+	}
+	contents, err := downloadArchive(aws.StringValue(code.Code.Location))
+	if err != nil {
+		return err
+	}
+	archive, err := stageArchive(out, cb, cb.Function, contents)
+	if err != nil {
+		return err
+	}
+	_, err = svc.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
+		FunctionName:  aws.String(cb.Function),
+		ZipFile:       archive.ZipFile,
+		S3Bucket:      archive.Bucket,
+		S3Key:         archive.Key,
+		Architectures: target.Architectures,
+	})
+	if err != nil {
+		return err
+	}
+	if err := waitForFunction(out, svc, cb.Function); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Rolled back the code of %s from %s to %s, the code of version %s: %s\n", cb.Function, sha, aws.StringValue(target.CodeSha256), aws.StringValue(target.Version), aws.StringValue(target.Description))
+	return nil
+}
+
+func init() {
+	rollbackCmd.PersistentFlags().StringVar(&recipeFlag, "recipe", "", "path to the recipe file (defaults to recipe.yml or recipe.yaml).")
+	rollbackCmd.PersistentFlags().StringVar(&stageFlag, "stage", "", "recipe stage to overlay on the base recipe, e.g. prod.")
+	rollbackCmd.PersistentFlags().StringVar(&functionFlag, "function", "", "only roll back the named function from the recipe.")
+	rollbackCmd.PersistentFlags().DurationVar(&waitTimeoutFlag, "wait-timeout", 5*time.Minute, "how long to wait for a function update to finish.")
+	rollbackCmd.PersistentFlags().IntVar(&toFlag, "to", 0, "version to roll back to, instead of the previous one.")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestRollbackTarget(t *testing.T) {
+	var versions []*lambda.FunctionConfiguration
+	for i, sha := range []string{"a", "b", "b", "c"} {
+		versions = append(versions, &lambda.FunctionConfiguration{
+			Version:    aws.String(strconv.Itoa(i + 1)),
+			CodeSha256: aws.String(sha),
+		})
+	}
+	olderThan := func(n int) func(*lambda.FunctionConfiguration) bool {
+		return func(v *lambda.FunctionConfiguration) bool { return versionNumber(v) < n }
+	}
+	otherCode := func(sha string) func(*lambda.FunctionConfiguration) bool {
+		return func(v *lambda.FunctionConfiguration) bool { return aws.StringValue(v.CodeSha256) != sha }
+	}
+	tests := []struct {
+		name   string
+		to     int
+		accept func(*lambda.FunctionConfiguration) bool
+		want   string
+		err    string
+	}{
+		{name: "previous version", accept: olderThan(4), want: "3"},
+		{name: "skips versions with the same code", accept: otherCode("c"), want: "3"},
+		{name: "code published twice", accept: func(v *lambda.FunctionConfiguration) bool { return otherCode("b")(v) && olderThan(4)(v) }, want: "1"},
+		{name: "explicit version", to: 2, accept: olderThan(1), want: "2"},
+		{name: "unknown version", to: 9, accept: olderThan(4), err: "there is no published version 9 of orders"},
+		{name: "nothing earlier", accept: olderThan(1), err: "there is no earlier published version of orders"},
+	}
+	for _, tt := range tests {
+		target, err := rollbackTarget(versions, "orders", tt.to, tt.accept)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: rollbackTarget() error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: rollbackTarget() error = %v", tt.name, err)
+			continue
+		}
+		if got := aws.StringValue(target.Version); got != tt.want {
+			t.Errorf("%s: rollbackTarget() = version %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := rollbackTarget(nil, "orders", 0, olderThan(4)); err == nil {
+		t.Error("rollbackTarget() without published versions did not fail")
+	}
+}
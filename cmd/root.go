@@ -1,29 +1,15 @@
 package cmd
 
 import (
-	"archive/zip"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 
+	"chefcli/recipe"
+
 	"github.com/spf13/cobra"
 )
 
-type Cookbook struct {
-	Recipe      []byte
-	Awscreds    string ""
-	Function    string `yaml:"function"`
-	Zipfile     string `yaml:"zipfile"`
-	Handler     string `yaml:"handler"`
-	ARN         string `yaml:"arn"`
-	Runtime     string `yaml:"runtime"`
-	Layer       string `yaml:"layer"`
-	Description string `yaml:"description"`
-	Tfplan      string ""
-	//	Bucket   string `yaml:"bucket"`
-}
-
 var (
 	Now    bool
 	Update bool
@@ -44,8 +30,12 @@ var rootCmd = &cobra.Command{
 	Example: 
 	chefcli cook terraform`,
 	ValidArgs: []string{
+		"alias",
 		"cook",
 		"create",
+		"layer",
+		"recipe",
+		"rollback",
 	},
 	Args:    cobra.OnlyValidArgs,
 	Version: version,
@@ -69,6 +59,32 @@ func CheckError(err error) {
 	}
 }
 
+// Function to load the recipe selected with --recipe and --stage, exiting on failure.
+func LoadRecipe() *recipe.Cookbook {
+	cb, err := loadRecipe(recipeFlag)
+	if err != nil {
+		fmt.Println(redact(err.Error()))
+		os.Exit(1)
+	}
+	if cb.Stage != "" {
+		fmt.Println("Using recipe stage " + cb.Stage + ".")
+	}
+	return cb
+}
+
+// Function to load a recipe with the --stage overlay, resolving references.
+// Errors may quote resolved secrets and must be redacted before printing.
+func loadRecipe(path string) (*recipe.Cookbook, error) {
+	cb, err := recipe.LoadWith(path, recipe.Options{
+		Stage:    stageFlag,
+		Resolver: &awsResolver{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cb, nil
+}
+
 // Function to check if a file exists
 func FileExists(filename string) bool {
 	info, err := os.Stat(filename)
@@ -78,54 +94,11 @@ func FileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-// Zip crawler to add files from a directory and sub-dirs
-func AddFiles(w *zip.Writer, basePath, baseInZip string) {
-	// check if basePath is not a Dir
-	ing, err := ioutil.ReadFile(basePath)
-	if err == nil {
-		f, err := w.Create(basePath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		_, err = f.Write(ing)
-	} else {
-		// Else, open the Directory
-		files, err := ioutil.ReadDir(basePath)
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		for _, file := range files {
-			fmt.Println(basePath + file.Name())
-			if !file.IsDir() {
-				dat, err := ioutil.ReadFile(basePath + file.Name())
-				if err != nil {
-					fmt.Println(err)
-				}
-
-				// Add some files to the archive.
-				f, err := w.Create(baseInZip + file.Name())
-				if err != nil {
-					fmt.Println(err)
-				}
-				_, err = f.Write(dat)
-				if err != nil {
-					fmt.Println(err)
-				}
-			} else if file.IsDir() {
-
-				// Recurse
-				newBase := basePath + "/" + file.Name() + "/"
-				fmt.Println("Recursing and Adding SubDir: " + file.Name())
-				fmt.Println("Recursing and Adding SubDir: " + newBase)
-
-				AddFiles(w, newBase, baseInZip+file.Name()+"/")
-			}
-		}
-	}
-}
-
 func init() {
+	rootCmd.AddCommand(aliasCmd)
 	rootCmd.AddCommand(cookCmd)
 	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(layerCmd)
+	rootCmd.AddCommand(recipeCmd)
+	rootCmd.AddCommand(rollbackCmd)
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"chefcli/recipe"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// maxInlineBytes is the largest archive Lambda accepts inline in a request.
+const maxInlineBytes = 50 * 1024 * 1024
+
+// artifact is where Lambda reads an archive from, either inline or from an
+// S3 object.
+type artifact struct {
+	ZipFile []byte
+	Bucket  *string
+	Key     *string
+}
+
+// Function to hand an archive to Lambda. With a recipe bucket every archive
+// is uploaded to it under a key made of the name and the SHA-256 of the
+// archive, so an archive that is already uploaded is not sent again. Without
+// one the archive is sent inline, which Lambda limits in size.
+func stageArchive(out io.Writer, cb *recipe.Cookbook, name string, contents []byte) (*artifact, error) {
+	size := float64(len(contents)) / (1024 * 1024)
+	if cb.Bucket == "" {
+		if len(contents) > maxInlineBytes {
+			return nil, fmt.Errorf("the archive is %.1f MB, over the %d MB Lambda accepts inline. Set bucket in the recipe to deploy it from S3", size, maxInlineBytes/(1024*1024))
+		}
+		return &artifact{ZipFile: contents}, nil
+	}
+
+	sum := sha256.Sum256(contents)
+	key := "chefcli/" + name + "/" + hex.EncodeToString(sum[:]) + ".zip"
+	svc := s3.New(newSession(), s3Config())
+
+	_, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(cb.Bucket), Key: aws.String(key)})
+	if err == nil {
+		fmt.Fprintf(out, "The archive is already uploaded to s3://%s/%s.\n", cb.Bucket, key)
+		return &artifact{Bucket: aws.String(cb.Bucket), Key: aws.String(key)}, nil
+	}
+	if aerr, ok := err.(awserr.RequestFailure); !ok || aerr.StatusCode() != 404 {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "The archive is %.1f MB, uploading it to s3://%s/%s.\n", size, cb.Bucket, key)
+	uploader := s3manager.NewUploaderWithClient(svc)
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(cb.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(contents),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &artifact{Bucket: aws.String(cb.Bucket), Key: aws.String(key)}, nil
+}
+
+// Function to configure the S3 client. Local S3-compatible stand-ins set
+// with CHEFCLI_S3_ENDPOINT are addressed by path, as they seldom serve
+// bucket subdomains.
+func s3Config() *aws.Config {
+	cfg := endpointConfig("s3")
+	if os.Getenv("CHEFCLI_S3_ENDPOINT") != "" {
+		cfg = cfg.WithS3ForcePathStyle(true)
+	}
+	return cfg
+}
+
+// Function to get the function code of an artifact.
+func (a *artifact) functionCode() *lambda.FunctionCode {
+	return &lambda.FunctionCode{ZipFile: a.ZipFile, S3Bucket: a.Bucket, S3Key: a.Key}
+}
+
+// Function to get the layer content of an artifact.
+func (a *artifact) layerContent() *lambda.LayerVersionContentInput {
+	return &lambda.LayerVersionContentInput{ZipFile: a.ZipFile, S3Bucket: a.Bucket, S3Key: a.Key}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+var (
+	waitTimeoutFlag time.Duration
+)
+
+// maxWaitDelay caps the time between two checks of a function state.
+const maxWaitDelay = 5 * time.Second
+
+// Function to wait until a function is Active and its last update is
+// Successful, after a change. Every change of state is printed while
+// waiting, and a failed state or the --wait-timeout ends the wait with an
+// error.
+func waitForFunction(out io.Writer, svc *lambda.Lambda, function string) error {
+	return waitUntil(out, svc, function, changeDone)
+}
+
+// Function to wait, before a change, until Lambda accepts one: it rejects
+// changes while a function is pending or being updated. An inactive function
+// is ready, the change makes it active again, and so is one whose last
+// update failed, as the change may be its fix.
+func waitForChange(out io.Writer, svc *lambda.Lambda, function string) error {
+	return waitUntil(out, svc, function, readyForChange)
+}
+
+// Function to tell whether a change is done, or failed.
+func changeDone(config *lambda.FunctionConfiguration) (bool, error) {
+	state := aws.StringValue(config.State)
+	update := aws.StringValue(config.LastUpdateStatus)
+	if state == lambda.StateFailed {
+		return false, fmt.Errorf("%s failed to become active: %s", aws.StringValue(config.FunctionName), aws.StringValue(config.StateReason))
+	}
+	if update == lambda.LastUpdateStatusFailed {
+		return false, fmt.Errorf("the last update of %s failed: %s", aws.StringValue(config.FunctionName), aws.StringValue(config.LastUpdateStatusReason))
+	}
+	return (state == "" || state == lambda.StateActive) && (update == "" || update == lambda.LastUpdateStatusSuccessful), nil
+}
+
+// Function to tell whether Lambda accepts a change.
+func readyForChange(config *lambda.FunctionConfiguration) (bool, error) {
+	return aws.StringValue(config.State) != lambda.StatePending && aws.StringValue(config.LastUpdateStatus) != lambda.LastUpdateStatusInProgress, nil
+}
+
+// Function to poll the configuration of a function until ready says so.
+func waitUntil(out io.Writer, svc *lambda.Lambda, function string, ready func(*lambda.FunctionConfiguration) (bool, error)) error {
+	start := time.Now()
+	delay := 500 * time.Millisecond
+	last := ""
+	for {
+		config, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: aws.String(function),
+		})
+		if err != nil {
+			return err
+		}
+		ok, err := ready(config)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if last != "" {
+				fmt.Fprintf(out, "%s is ready after %s.\n", function, time.Since(start).Round(time.Second))
+			}
+			return nil
+		}
+
+		status := "state " + aws.StringValue(config.State) + ", last update " + aws.StringValue(config.LastUpdateStatus)
+		if status != last {
+			fmt.Fprintf(out, "Waiting for %s, %s.\n", function, status)
+			last = status
+		}
+		if time.Since(start) > waitTimeoutFlag {
+			return fmt.Errorf("%s is not ready after %s, %s. Raise --wait-timeout to wait longer", function, waitTimeoutFlag, status)
+		}
+
+		time.Sleep(delay)
+		if delay *= 2; delay > maxWaitDelay {
+			delay = maxWaitDelay
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func TestWaitStates(t *testing.T) {
+	tests := []struct {
+		state, update string
+		ready         bool // before a change
+		done          bool // after a change
+		failed        bool // after a change
+	}{
+		{"", "", true, true, false},
+		{lambda.StateActive, lambda.LastUpdateStatusSuccessful, true, true, false},
+		{lambda.StatePending, "", false, false, false},
+		{lambda.StateActive, lambda.LastUpdateStatusInProgress, false, false, false},
+		{lambda.StateInactive, lambda.LastUpdateStatusSuccessful, true, false, false},
+		{lambda.StateActive, lambda.LastUpdateStatusFailed, true, false, true},
+		{lambda.StateFailed, "", true, false, true},
+	}
+	for _, tt := range tests {
+		config := &lambda.FunctionConfiguration{
+			FunctionName:     aws.String("orders"),
+			State:            aws.String(tt.state),
+			LastUpdateStatus: aws.String(tt.update),
+		}
+		if ready, err := readyForChange(config); ready != tt.ready || err != nil {
+			t.Errorf("readyForChange(%s, %s) = %v, %v, want %v", tt.state, tt.update, ready, err, tt.ready)
+		}
+		done, err := changeDone(config)
+		if done != tt.done || (err != nil) != tt.failed {
+			t.Errorf("changeDone(%s, %s) = %v, %v, want %v and failed %v", tt.state, tt.update, done, err, tt.done, tt.failed)
+		}
+	}
+}
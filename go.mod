@@ -3,8 +3,9 @@ module chefcli
 go 1.15
 
 require (
-	github.com/aws/aws-sdk-go v1.35.7
+	github.com/aws/aws-sdk-go v1.44.100
 	github.com/prometheus/common v0.4.0
 	github.com/spf13/cobra v1.0.0
 	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,150 @@
+package recipe
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Resolver looks up the value behind a ${ssm:/path} or ${secret:name}
+// reference. kind is "ssm" or "secret".
+type Resolver interface {
+	Resolve(kind, name string) (string, error)
+}
+
+var referencePattern = regexp.MustCompile(`\$\{(env|ssm|secret):([^}]+)\}`)
+
+// HasReference reports whether s contains a ${env:...}, ${ssm:...} or
+// ${secret:...} reference.
+func HasReference(s string) bool {
+	return referencePattern.MatchString(s)
+}
+
+// Interpolate replaces references in every string of the Cookbook,
+// including its function entries. ${env:VAR} is read from the environment,
+// ${ssm:/path} and ${secret:name} are looked up with r, once per reference.
+// The resolver is in charge of keeping the values out of any output. Stages
+// that were not overlaid are left untouched.
+func (cb *Cookbook) Interpolate(r Resolver) error {
+	in := &interpolator{resolver: r, cache: map[string]string{}}
+	v := reflect.ValueOf(cb).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" || t.Field(i).Name == "Stages" || t.Field(i).Name == "Recipe" {
+			continue
+		}
+		if err := in.value(v.Field(i)); err != nil {
+			return err
+		}
+	}
+	for i, fields := range cb.Functions {
+		resolved, err := in.generic(fields)
+		if err != nil {
+			return err
+		}
+		cb.Functions[i] = resolved.(yaml.MapSlice)
+	}
+	return nil
+}
+
+type interpolator struct {
+	resolver Resolver
+	cache    map[string]string
+}
+
+func (in *interpolator) value(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := in.expand(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := in.value(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			s, err := in.expand(v.MapIndex(key).String())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(key, reflect.ValueOf(s))
+		}
+	}
+	return nil
+}
+
+// generic expands the strings of a value decoded into a yaml.MapSlice.
+func (in *interpolator) generic(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return in.expand(x)
+	case yaml.MapSlice:
+		for i := range x {
+			value, err := in.generic(x[i].Value)
+			if err != nil {
+				return nil, err
+			}
+			x[i].Value = value
+		}
+		return x, nil
+	case []interface{}:
+		for i := range x {
+			value, err := in.generic(x[i])
+			if err != nil {
+				return nil, err
+			}
+			x[i] = value
+		}
+		return x, nil
+	}
+	return v, nil
+}
+
+func (in *interpolator) expand(s string) (string, error) {
+	var err error
+	expanded := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		if value, ok := in.cache[ref]; ok {
+			return value
+		}
+		m := referencePattern.FindStringSubmatch(ref)
+		kind, name := m[1], m[2]
+		var value string
+		switch kind {
+		case "env":
+			var ok bool
+			if value, ok = os.LookupEnv(name); !ok {
+				err = &ValidationError{Field: ref, Message: fmt.Sprintf("The environment variable %s used by %s is not set.", name, ref)}
+				return ref
+			}
+		default:
+			if in.resolver == nil {
+				err = &ValidationError{Field: ref, Message: fmt.Sprintf("Cannot resolve %s without AWS access.", ref)}
+				return ref
+			}
+			if value, err = in.resolver.Resolve(kind, name); err != nil {
+				err = fmt.Errorf("resolving %s: %w", ref, err)
+				return ref
+			}
+		}
+		in.cache[ref] = value
+		return value
+	})
+	return expanded, err
+}
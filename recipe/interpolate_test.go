@@ -0,0 +1,119 @@
+package recipe
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeResolver serves references from a map and counts the lookups.
+type fakeResolver struct {
+	values map[string]string
+	calls  int
+}
+
+func (r *fakeResolver) Resolve(kind, name string) (string, error) {
+	r.calls++
+	value, ok := r.values[kind+":"+name]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("CHEFCLI_TEST_STAGE", "prod")
+	defer os.Unsetenv("CHEFCLI_TEST_STAGE")
+
+	tests := []struct {
+		name     string
+		recipe   string
+		resolver *fakeResolver
+		env      map[string]string
+		calls    int
+		err      string
+	}{
+		{
+			name:   "environment variables",
+			recipe: "environment:\n  STAGE: ${env:CHEFCLI_TEST_STAGE}-eu\n",
+			env:    map[string]string{"STAGE": "prod-eu"},
+		},
+		{
+			name:     "ssm and secret references",
+			recipe:   "environment:\n  DB: ${ssm:/db/url}\n  KEY: ${secret:api}\n  PLAIN: value\n",
+			resolver: &fakeResolver{values: map[string]string{"ssm:/db/url": "postgres://db", "secret:api": "s3cr3t"}},
+			env:      map[string]string{"DB": "postgres://db", "KEY": "s3cr3t", "PLAIN": "value"},
+			calls:    2,
+		},
+		{
+			name:     "repeated references resolve once",
+			recipe:   "environment:\n  A: ${secret:api}\n  B: ${secret:api}\n",
+			resolver: &fakeResolver{values: map[string]string{"secret:api": "s3cr3t"}},
+			env:      map[string]string{"A": "s3cr3t", "B": "s3cr3t"},
+			calls:    1,
+		},
+		{
+			name:   "unset environment variable",
+			recipe: "environment:\n  STAGE: ${env:CHEFCLI_TEST_UNSET}\n",
+			err:    "CHEFCLI_TEST_UNSET used by ${env:CHEFCLI_TEST_UNSET} is not set",
+		},
+		{
+			name:   "no resolver",
+			recipe: "environment:\n  DB: ${ssm:/db/url}\n",
+			err:    "Cannot resolve ${ssm:/db/url} without AWS access.",
+		},
+		{
+			name:     "resolver error",
+			recipe:   "environment:\n  DB: ${ssm:/db/missing}\n",
+			resolver: &fakeResolver{},
+			err:      "resolving ${ssm:/db/missing}: not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, err := Parse([]byte(tt.recipe))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r Resolver
+			if tt.resolver != nil {
+				r = tt.resolver
+			}
+			err = cb.Interpolate(r)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Interpolate() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Interpolate() error = %v", err)
+			}
+			for key, want := range tt.env {
+				if got := cb.Environment[key]; got != want {
+					t.Errorf("Interpolate() %s = %q, want %q", key, got, want)
+				}
+			}
+			if tt.resolver != nil && tt.resolver.calls != tt.calls {
+				t.Errorf("Interpolate() resolved %d times, want %d", tt.resolver.calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestInterpolateFunctions(t *testing.T) {
+	cb, err := Parse([]byte("handler: handler\nfunctions:\n  - function: orders\n    description: ${secret:api}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Interpolate(&fakeResolver{values: map[string]string{"secret:api": "s3cr3t"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if got := cb.All()[0].Description; got != "s3cr3t" {
+		t.Errorf("Interpolate() function description = %q, want s3cr3t", got)
+	}
+}
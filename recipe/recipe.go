@@ -0,0 +1,519 @@
+// Package recipe loads and validates the recipe.yml / recipe.yaml files that
+// describe what ChefCLI cooks.
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultRuntime is used when a recipe does not specify a runtime.
+const DefaultRuntime = "python3.8"
+
+// KnownRuntimes are the Lambda runtimes a recipe may use.
+var KnownRuntimes = []string{"python3.7", "python3.8", "python3.9", "python3.10", "python3.11", "python3.12", "python3.13"}
+
+var roleARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// DependencySources are where a recipe may list its layer packages:
+// requirements.txt, poetry.lock, Pipfile.lock, uv.lock or pyproject.toml.
+var DependencySources = []string{"requirements", "poetry", "pipenv", "uv", "pyproject"}
+
+// DefaultFiles are the recipe file names probed, in order, when no path is given.
+var DefaultFiles = []string{"recipe.yml", "recipe.yaml"}
+
+// ErrNotFound is returned by Load when no recipe file could be located.
+var ErrNotFound = errors.New("there is no recipe.yml or recipe.yaml file present. Please set one up")
+
+// Cookbook holds the contents of a recipe.
+type Cookbook struct {
+	Recipe      []byte `yaml:"-"`
+	Awscreds    string `yaml:"-"`
+	Function    string `yaml:"function"`
+	Zipfile     string `yaml:"zipfile"`
+	Handler     string `yaml:"handler"`
+	ARN         string `yaml:"arn"`
+	Runtime     string `yaml:"runtime"`
+	Layer       string `yaml:"layer"`
+	Description string `yaml:"description"`
+	Tfplan      string `yaml:"-"`
+
+	// Bucket is the S3 bucket archives are deployed from. Without it archives
+	// are sent inline, up to 50 MB. It must be in the region of the function.
+	Bucket string `yaml:"bucket"`
+
+	// Alias is moved to the version published by cook lambda --publish.
+	Alias string `yaml:"alias"`
+
+	Memory           int64             `yaml:"memory"`
+	Timeout          int64             `yaml:"timeout"`
+	Environment      map[string]string `yaml:"environment"`
+	Tags             map[string]string `yaml:"tags"`
+	EphemeralStorage int64             `yaml:"ephemeral_storage"`
+	Architectures    []string          `yaml:"architectures"`
+
+	// Stages overlay fields of the base recipe, selected with Stage.
+	Stages map[string]yaml.MapSlice `yaml:"stages"`
+	// Stage is the name of the stage merged into this Cookbook, if any.
+	Stage string `yaml:"-"`
+
+	// Functions overlay fields of the base recipe, one entry per function.
+	Functions []yaml.MapSlice `yaml:"functions"`
+	// Module names the Python file, <module>.py, and the virtual environment
+	// folder of the function, and is the module of a handler given without
+	// one. It defaults to the function name before a stage renames it.
+	Module string `yaml:"module"`
+
+	// Dir is the folder holding the function sources, relative to the
+	// current folder.
+	Dir string `yaml:"dir"`
+
+	// Source lists the files and folders to package, relative to Dir, keeping
+	// their layout inside the archive. Without it only <function>.py is
+	// packaged.
+	Source Paths `yaml:"source"`
+
+	// Exclude and Include are gitignore-style globs applied to archive
+	// entries after .chefignore, Include winning over both.
+	Exclude []string `yaml:"exclude"`
+	Include []string `yaml:"include"`
+
+	// Builder installs the layer packages, in a container (the default) or
+	// with the local pip. Engine is the container engine, docker, podman or
+	// nerdctl. Wheelhouse is a folder of wheels for offline pip installs.
+	Builder    string `yaml:"builder"`
+	Engine     string `yaml:"engine"`
+	Wheelhouse string `yaml:"wheelhouse"`
+
+	// Dependencies is where the layer packages are listed, one of
+	// DependencySources. Without it the first file found is used.
+	Dependencies string `yaml:"dependencies"`
+
+	// CompatibleRuntimes and CompatibleArchitectures are published with the
+	// layer. They default to the runtime and architectures of the recipe.
+	CompatibleRuntimes      []string `yaml:"compatible_runtimes"`
+	CompatibleArchitectures []string `yaml:"compatible_architectures"`
+
+	functions []*Cookbook
+}
+
+// Paths is a list of paths that may also be written as a single path.
+type Paths []string
+
+// UnmarshalYAML accepts either a single path or a list of paths.
+func (p *Paths) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*p = Paths{single}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*p = list
+	return nil
+}
+
+// ValidationError reports a recipe field that is missing or invalid.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Find returns the recipe path to use. An empty path probes DefaultFiles in the
+// current folder, a folder path probes DefaultFiles inside that folder.
+func Find(path string) (string, error) {
+	dir := "."
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("recipe %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			return path, nil
+		}
+		dir = path
+	}
+	for _, name := range DefaultFiles {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	if dir != "." {
+		return "", fmt.Errorf("%s: %w", dir, ErrNotFound)
+	}
+	return "", ErrNotFound
+}
+
+// Load reads the recipe at path (or the default recipe file when path is
+// empty), applies defaults and validates the fields every recipe needs.
+func Load(path string) (*Cookbook, error) {
+	return LoadStage(path, "")
+}
+
+// LoadStage is like Load but first overlays the named stage on the base
+// recipe. An empty stage loads the base recipe.
+func LoadStage(path, stage string) (*Cookbook, error) {
+	return LoadWith(path, Options{Stage: stage})
+}
+
+// Options control how LoadWith prepares a recipe.
+type Options struct {
+	// Stage is overlaid on the base recipe when set.
+	Stage string
+	// Resolver resolves ${ssm:...} and ${secret:...} references. Without
+	// one, such references are an error.
+	Resolver Resolver
+}
+
+// LoadWith is like Load but overlays opts.Stage and resolves references
+// before validating.
+func LoadWith(path string, opts Options) (*Cookbook, error) {
+	path, err := Find(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cb, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if opts.Stage != "" {
+		if cb, err = cb.Overlay(opts.Stage); err != nil {
+			return nil, err
+		}
+	}
+	if err := cb.Interpolate(opts.Resolver); err != nil {
+		return nil, err
+	}
+	if err := cb.Prepare(); err != nil {
+		return nil, err
+	}
+	return cb, nil
+}
+
+// Parse unmarshals recipe data without applying defaults or validation of
+// the values. Unknown or duplicate fields and values of the wrong type
+// anywhere in the recipe are returned as Problems.
+func Parse(data []byte) (*Cookbook, error) {
+	if _, problems := checkStructure(data, false); len(problems) > 0 {
+		return nil, Problems(problems)
+	}
+	return parse(data)
+}
+
+// parse unmarshals recipe data leniently. On a *yaml.TypeError the Cookbook
+// is returned too, holding the fields that did decode.
+func parse(data []byte) (*Cookbook, error) {
+	cb := &Cookbook{Recipe: data}
+	err := yaml.Unmarshal(data, cb)
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return nil, err
+	}
+	return cb, err
+}
+
+// Overlay returns a copy of the Cookbook with the named stage merged on top.
+// Fields set in the stage replace the base ones, environment and tags are
+// merged key by key.
+func (cb *Cookbook) Overlay(stage string) (*Cookbook, error) {
+	fields, ok := cb.Stages[stage]
+	if !ok {
+		return nil, &ValidationError{Field: "stages", Message: fmt.Sprintf("There is no stage %q in your Recipe. Available stages: %s.", stage, strings.Join(cb.StageNames(), ", "))}
+	}
+	// keep the sources of the base function when the stage renames it
+	base := *cb
+	if base.Module == "" {
+		base.Module = base.Function
+	}
+	merged, err := base.merge(fields, "stage "+stage, "stages")
+	if err != nil {
+		return nil, err
+	}
+	merged.Stage = stage
+	return merged, nil
+}
+
+// merge returns a copy of the Cookbook with fields unmarshalled on top. Keys
+// listed in reserved are rejected.
+func (cb *Cookbook) merge(fields yaml.MapSlice, where string, reserved ...string) (*Cookbook, error) {
+	for _, item := range fields {
+		key, _ := item.Key.(string)
+		for _, r := range reserved {
+			if key == r {
+				return nil, &ValidationError{Field: r, Message: fmt.Sprintf("The %s cannot define %s.", where, r)}
+			}
+		}
+	}
+
+	merged := *cb
+	merged.Environment = copyMap(cb.Environment)
+	merged.Tags = copyMap(cb.Tags)
+	data, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("%s: %w", where, err)
+	}
+	return &merged, nil
+}
+
+// StageNames returns the sorted names of the stages in the recipe.
+func (cb *Cookbook) StageNames() []string {
+	names := make([]string, 0, len(cb.Stages))
+	for name := range cb.Stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Prepare validates the Cookbook and fills in defaults. A handler without a
+// module is composed as <module>.<handler>. When the recipe lists functions, each
+// entry is merged on top of the base recipe and prepared on its own.
+func (cb *Cookbook) Prepare() error {
+	if cb.Runtime == "" {
+		cb.Runtime = DefaultRuntime
+	}
+	if len(cb.Functions) == 0 {
+		if err := cb.prepareFunction(); err != nil {
+			return err
+		}
+		cb.functions = []*Cookbook{cb}
+		return nil
+	}
+
+	cb.functions = nil
+	seen := map[string]bool{}
+	for i, fields := range cb.Functions {
+		where := fmt.Sprintf("functions[%d]", i)
+		// each entry has its own module unless it names one
+		base := *cb
+		base.Module = ""
+		fn, err := base.merge(fields, where+" entry", "stages", "functions")
+		if err != nil {
+			return err
+		}
+		fn.Functions = nil
+		if err := fn.prepareFunction(); err != nil {
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				return &ValidationError{Field: where + "." + ve.Field, Message: where + ": " + ve.Message}
+			}
+			return err
+		}
+		if seen[fn.Function] {
+			return &ValidationError{Field: where + ".function", Message: fmt.Sprintf("Function %s is listed more than once in your Recipe.", fn.Function)}
+		}
+		seen[fn.Function] = true
+		fn.functions = []*Cookbook{fn}
+		cb.functions = append(cb.functions, fn)
+	}
+	return nil
+}
+
+func (cb *Cookbook) prepareFunction() error {
+	if err := cb.Validate(); err != nil {
+		return err
+	}
+	if cb.Module == "" {
+		cb.Module = cb.Function
+	}
+	if !strings.Contains(cb.Handler, ".") {
+		cb.Handler = cb.Module + "." + cb.Handler
+	}
+	return nil
+}
+
+// HandlerModule returns the path of the Python file holding the handler,
+// e.g. app/main.py for app.main.handler.
+func (cb *Cookbook) HandlerModule() string {
+	module := cb.Handler
+	if i := strings.LastIndex(module, "."); i >= 0 {
+		module = module[:i]
+	}
+	return strings.ReplaceAll(module, ".", "/") + ".py"
+}
+
+// SitePackages returns the site-packages folder of the recipe runtime,
+// relative to a virtual environment or layer python folder.
+func (cb *Cookbook) SitePackages() string {
+	return "lib/" + cb.Runtime + "/site-packages"
+}
+
+// LayerSitePackages returns the folder of the layer packages, relative to
+// the layer python folder. A layer for several runtimes keeps them in the
+// python folder itself, which every Python runtime has on its path.
+func (cb *Cookbook) LayerSitePackages() string {
+	runtimes := cb.LayerRuntimes()
+	if len(runtimes) > 1 {
+		return ""
+	}
+	return "lib/" + runtimes[0] + "/site-packages"
+}
+
+// LayerRuntimes returns the runtimes the layer is compatible with.
+func (cb *Cookbook) LayerRuntimes() []string {
+	if len(cb.CompatibleRuntimes) > 0 {
+		return cb.CompatibleRuntimes
+	}
+	return []string{cb.Runtime}
+}
+
+// LayerArchitectures returns the architectures the layer is compatible
+// with, none meaning any.
+func (cb *Cookbook) LayerArchitectures() []string {
+	if len(cb.CompatibleArchitectures) > 0 {
+		return cb.CompatibleArchitectures
+	}
+	return cb.Architectures
+}
+
+// HandlerName returns the name of the handler function, e.g. handler for
+// app.main.handler.
+func (cb *Cookbook) HandlerName() string {
+	return cb.Handler[strings.LastIndex(cb.Handler, ".")+1:]
+}
+
+// All returns one prepared Cookbook per function in the recipe. A recipe
+// without a functions list yields itself.
+func (cb *Cookbook) All() []*Cookbook {
+	return cb.functions
+}
+
+// Lookup returns the prepared Cookbook of the named function.
+func (cb *Cookbook) Lookup(function string) (*Cookbook, error) {
+	names := make([]string, 0, len(cb.functions))
+	for _, fn := range cb.functions {
+		if fn.Function == function {
+			return fn, nil
+		}
+		names = append(names, fn.Function)
+	}
+	return nil, &ValidationError{Field: "functions", Message: fmt.Sprintf("There is no function %q in your Recipe. Available functions: %s.", function, strings.Join(names, ", "))}
+}
+
+// Validate checks the fields every recipe needs.
+func (cb *Cookbook) Validate() error {
+	if cb.Function == "" {
+		return &ValidationError{Field: "function", Message: "There is no Function name. Please supply a function name in your Recipe."}
+	}
+	if cb.Handler == "" {
+		return &ValidationError{Field: "handler", Message: "There is no Handler name. Please supply a handler name in your Recipe."}
+	}
+	if errs := cb.checkFields(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// checkFields returns every field set to a value AWS would reject.
+func (cb *Cookbook) checkFields() []*ValidationError {
+	var errs []*ValidationError
+	if cb.ARN != "" && !HasReference(cb.ARN) && !roleARNPattern.MatchString(cb.ARN) {
+		errs = append(errs, &ValidationError{Field: "arn", Message: fmt.Sprintf("%q is not an IAM role ARN like arn:aws:iam::123456789012:role/name.", cb.ARN)})
+	}
+	if cb.Runtime != "" && !knownRuntime(cb.Runtime) {
+		errs = append(errs, &ValidationError{Field: "runtime", Message: fmt.Sprintf("Unknown runtime %q. Use one of %s.", cb.Runtime, strings.Join(KnownRuntimes, ", "))})
+	}
+	for _, runtime := range cb.CompatibleRuntimes {
+		if !knownRuntime(runtime) {
+			errs = append(errs, &ValidationError{Field: "compatible_runtimes", Message: fmt.Sprintf("Unknown runtime %q. Use one of %s.", runtime, strings.Join(KnownRuntimes, ", "))})
+		}
+	}
+	for _, arch := range cb.CompatibleArchitectures {
+		if arch != "x86_64" && arch != "arm64" {
+			errs = append(errs, &ValidationError{Field: "compatible_architectures", Message: fmt.Sprintf("Unknown architecture %q. Use x86_64 or arm64.", arch)})
+		}
+	}
+	if cb.Memory != 0 && (cb.Memory < 128 || cb.Memory > 10240) {
+		errs = append(errs, &ValidationError{Field: "memory", Message: fmt.Sprintf("Memory must be between 128 and 10240 MB, got %d.", cb.Memory)})
+	}
+	if cb.Timeout != 0 && (cb.Timeout < 1 || cb.Timeout > 900) {
+		errs = append(errs, &ValidationError{Field: "timeout", Message: fmt.Sprintf("Timeout must be between 1 and 900 seconds, got %d.", cb.Timeout)})
+	}
+	if cb.EphemeralStorage != 0 && (cb.EphemeralStorage < 512 || cb.EphemeralStorage > 10240) {
+		errs = append(errs, &ValidationError{Field: "ephemeral_storage", Message: fmt.Sprintf("Ephemeral storage must be between 512 and 10240 MB, got %d.", cb.EphemeralStorage)})
+	}
+	if cb.Builder != "" && cb.Builder != "container" && cb.Builder != "pip" {
+		errs = append(errs, &ValidationError{Field: "builder", Message: fmt.Sprintf("Unknown builder %q. Use container or pip.", cb.Builder)})
+	}
+	if cb.Engine != "" && cb.Engine != "docker" && cb.Engine != "podman" && cb.Engine != "nerdctl" {
+		errs = append(errs, &ValidationError{Field: "engine", Message: fmt.Sprintf("Unknown container engine %q. Use docker, podman or nerdctl.", cb.Engine)})
+	}
+	if cb.Alias != "" && !HasReference(cb.Alias) && (!aliasPattern.MatchString(cb.Alias) || strings.Trim(cb.Alias, "0123456789") == "") {
+		errs = append(errs, &ValidationError{Field: "alias", Message: fmt.Sprintf("Invalid alias %q. Use up to 128 letters, digits, - and _, not only digits.", cb.Alias)})
+	}
+	if cb.Dependencies != "" && !contains(DependencySources, cb.Dependencies) {
+		errs = append(errs, &ValidationError{Field: "dependencies", Message: fmt.Sprintf("Unknown dependency source %q. Use one of %s.", cb.Dependencies, strings.Join(DependencySources, ", "))})
+	}
+	if len(cb.Architectures) > 1 {
+		errs = append(errs, &ValidationError{Field: "architectures", Message: "Lambda functions support a single architecture."})
+	}
+	for _, arch := range cb.Architectures {
+		if arch != "x86_64" && arch != "arm64" {
+			errs = append(errs, &ValidationError{Field: "architectures", Message: fmt.Sprintf("Unknown architecture %q. Use x86_64 or arm64.", arch)})
+		}
+	}
+	return errs
+}
+
+func knownRuntime(runtime string) bool {
+	return contains(KnownRuntimes, runtime)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireARN checks that the recipe supplies a role ARN.
+func (cb *Cookbook) RequireARN() error {
+	if cb.ARN == "" {
+		return &ValidationError{Field: "arn", Message: "You must supply an ARN."}
+	}
+	return nil
+}
+
+// RequireLayer checks that the recipe supplies a layer name.
+func (cb *Cookbook) RequireLayer() error {
+	if cb.Layer == "" {
+		return &ValidationError{Field: "layer", Message: "There is no Layer name. Please supply a layer name in your Recipe."}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package recipe
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const stagedRecipe = `function: orders
+handler: handler
+arn: arn:aws:iam::123456789012:role/lambda
+environment:
+  LOG_LEVEL: info
+  TABLE: orders
+stages:
+  prod:
+    function: orders-prod
+    memory: 512
+    environment:
+      TABLE: orders-prod
+`
+
+// writeRecipe writes a recipe file to a temporary folder and returns its path.
+func writeRecipe(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		recipe  string
+		handler string
+		runtime string
+		err     string
+	}{
+		{"composes the handler", "recipe.yml", "function: orders\nhandler: handler\n", "orders.handler", DefaultRuntime, ""},
+		{"keeps a dotted handler", "recipe.yaml", "function: orders\nhandler: app.main.handler\nruntime: python3.12\n", "app.main.handler", "python3.12", ""},
+		{"follows module", "recipe.yml", "function: orders\nmodule: app\nhandler: handler\n", "app.handler", DefaultRuntime, ""},
+		{"missing function", "recipe.yml", "handler: handler\n", "", "", "There is no Function name"},
+		{"missing handler", "recipe.yml", "function: orders\n", "", "", "There is no Handler name"},
+		{"unknown field", "recipe.yml", "function: orders\nhanlder: handler\n", "", "", `Did you mean "handler"?`},
+		{"wrong type", "recipe.yml", "function: orders\nhandler: handler\nmemory: lots\n", "", "", "memory: Expected a whole number."},
+		{"bad value", "recipe.yml", "function: orders\nhandler: handler\ntimeout: 901\n", "", "", "Timeout must be between 1 and 900 seconds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeRecipe(t, tt.file, tt.recipe)
+			// a folder finds the default file inside it
+			cb, err := Load(filepath.Dir(path))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cb.Handler != tt.handler || cb.Runtime != tt.runtime {
+				t.Errorf("Load() handler, runtime = %s, %s, want %s, %s", cb.Handler, cb.Runtime, tt.handler, tt.runtime)
+			}
+		})
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	if _, err := Load(t.TempDir()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	base, err := Parse([]byte(stagedRecipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := base.Overlay("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prod.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	if prod.Function != "orders-prod" || prod.Memory != 512 || prod.Stage != "prod" {
+		t.Errorf("Overlay() function, memory, stage = %s, %d, %s", prod.Function, prod.Memory, prod.Stage)
+	}
+	// the sources keep the name of the base function
+	if prod.Module != "orders" || prod.Handler != "orders.handler" {
+		t.Errorf("Overlay() module, handler = %s, %s, want orders, orders.handler", prod.Module, prod.Handler)
+	}
+	if prod.Environment["TABLE"] != "orders-prod" || prod.Environment["LOG_LEVEL"] != "info" {
+		t.Errorf("Overlay() environment = %v", prod.Environment)
+	}
+	if base.Environment["TABLE"] != "orders" || base.Module != "" {
+		t.Errorf("Overlay() changed the base recipe: %v, module %q", base.Environment, base.Module)
+	}
+
+	if _, err := base.Overlay("dev"); err == nil || !strings.Contains(err.Error(), "Available stages: prod.") {
+		t.Errorf("Overlay(dev) error = %v", err)
+	}
+}
+
+func TestPrepareFunctions(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipe    string
+		functions []string
+		handlers  []string
+		err       string
+	}{
+		{
+			name:      "single function",
+			recipe:    "function: orders\nhandler: handler\n",
+			functions: []string{"orders"},
+			handlers:  []string{"orders.handler"},
+		},
+		{
+			name:      "entries merge on the base",
+			recipe:    "handler: handler\nmodule: shared\nfunctions:\n  - function: orders\n  - function: invoices\n    handler: billing.run\n",
+			functions: []string{"orders", "invoices"},
+			handlers:  []string{"orders.handler", "billing.run"},
+		},
+		{
+			name:   "duplicate function",
+			recipe: "handler: handler\nfunctions:\n  - function: orders\n  - function: orders\n",
+			err:    "Function orders is listed more than once",
+		},
+		{
+			name:   "entry without function",
+			recipe: "handler: handler\nfunctions:\n  - memory: 256\n",
+			err:    "functions[0]: There is no Function name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb, err := Parse([]byte(tt.recipe))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cb.Prepare()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Prepare() error = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Prepare() error = %v", err)
+			}
+			var functions, handlers []string
+			for _, fn := range cb.All() {
+				functions = append(functions, fn.Function)
+				handlers = append(handlers, fn.Handler)
+			}
+			if strings.Join(functions, ",") != strings.Join(tt.functions, ",") || strings.Join(handlers, ",") != strings.Join(tt.handlers, ",") {
+				t.Errorf("Prepare() functions %v handlers %v, want %v %v", functions, handlers, tt.functions, tt.handlers)
+			}
+		})
+	}
+}
+
+func TestLayerSitePackages(t *testing.T) {
+	tests := []struct {
+		runtime    string
+		compatible []string
+		want       string
+	}{
+		{"python3.12", nil, "lib/python3.12/site-packages"},
+		{"python3.12", []string{"python3.11"}, "lib/python3.11/site-packages"},
+		{"python3.12", []string{"python3.11", "python3.12"}, ""},
+	}
+	for _, tt := range tests {
+		cb := &Cookbook{Runtime: tt.runtime, CompatibleRuntimes: tt.compatible}
+		if got := cb.LayerSitePackages(); got != tt.want {
+			t.Errorf("LayerSitePackages() for %v = %q, want %q", tt.compatible, got, tt.want)
+		}
+	}
+}
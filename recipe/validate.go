@@ -0,0 +1,398 @@
+package recipe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Problem is a single issue found while checking a recipe. Line is 0 when
+// the problem cannot be tied to a line, e.g. a missing field.
+type Problem struct {
+	Line    int
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	where := ""
+	if p.Line > 0 {
+		where = fmt.Sprintf("line %d: ", p.Line)
+	}
+	if p.Field != "" {
+		where += p.Field + ": "
+	}
+	return where + p.Message
+}
+
+// field describes a recipe key and the Cookbook field it decodes into.
+type field struct {
+	Key   string
+	Index int
+	Type  reflect.Type
+}
+
+var cookbookFields = func() map[string]field {
+	fields := map[string]field{}
+	t := reflect.TypeOf(Cookbook{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		fields[key] = field{Key: key, Index: i, Type: f.Type}
+	}
+	return fields
+}()
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// Problems are the problems found in a recipe, as an error.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := []string{fmt.Sprintf("found %d problem%s in the recipe:", len(p), plural(len(p)))}
+	for _, problem := range p {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// Check decodes recipe data strictly and returns every problem found: syntax
+// errors, unknown keys, values of the wrong type, values AWS would reject and
+// fields missing once stages and functions are merged.
+func Check(data []byte) []Problem {
+	c, problems := checkStructure(data, true)
+	if c != nil {
+		c.merged(data)
+		problems = c.problems
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
+
+// checkStructure walks the recipe and reports syntax errors, unknown and
+// duplicate keys and values of the wrong type, and with values the values
+// AWS would reject. The checker is nil when the YAML cannot be read.
+func checkStructure(data []byte, values bool) (*checker, []Problem) {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		line := 0
+		if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		return nil, []Problem{{Line: line, Message: err.Error()}}
+	}
+	if len(root.Content) == 0 {
+		return nil, []Problem{{Message: "The recipe is empty."}}
+	}
+
+	c := &checker{stageLines: map[string]int{}, values: values}
+	c.mapping(root.Content[0], "", true, true)
+	return c, c.problems
+}
+
+type checker struct {
+	problems   []Problem
+	stageLines map[string]int
+	values     bool
+}
+
+func (c *checker) add(line int, field, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Line: line, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// mapping checks the keys of a base, stage or function mapping.
+func (c *checker) mapping(node *yamlv3.Node, path string, allowStages, allowFunctions bool) {
+	if node.Kind != yamlv3.MappingNode {
+		c.add(node.Line, strings.TrimSuffix(path, "."), "Expected a mapping of recipe fields.")
+		return
+	}
+
+	cb := &Cookbook{}
+	lines := map[string]int{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k, v := node.Content[i], node.Content[i+1]
+		key := k.Value
+		if _, dup := lines[key]; dup {
+			c.add(k.Line, path+key, "Duplicate field, first set on line %d.", lines[key])
+			continue
+		}
+		lines[key] = k.Line
+
+		f, ok := cookbookFields[key]
+		if !ok || (key == "stages" && !allowStages) || (key == "functions" && !allowFunctions) {
+			msg := fmt.Sprintf("Unknown field %q.", key)
+			if ok {
+				msg = fmt.Sprintf("Field %q is not allowed here.", key)
+			} else if s := suggestField(key); s != "" {
+				msg += fmt.Sprintf(" Did you mean %q?", s)
+			}
+			c.add(k.Line, path+key, "%s", msg)
+			continue
+		}
+
+		switch key {
+		case "stages":
+			if v.Kind != yamlv3.MappingNode {
+				c.add(v.Line, path+key, "Expected a mapping of stage names to recipe fields.")
+				continue
+			}
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				name := v.Content[j].Value
+				c.stageLines[name] = v.Content[j].Line
+				c.mapping(v.Content[j+1], path+"stages."+name+".", false, true)
+			}
+		case "functions":
+			if v.Kind != yamlv3.SequenceNode {
+				c.add(v.Line, path+key, "Expected a list of functions.")
+				continue
+			}
+			for j, item := range v.Content {
+				c.mapping(item, fmt.Sprintf("%sfunctions[%d].", path, j), false, false)
+			}
+		default:
+			value := reflect.New(f.Type)
+			if err := v.Decode(value.Interface()); err != nil {
+				c.add(v.Line, path+key, "Expected %s.", describeType(f.Type))
+				continue
+			}
+			reflect.ValueOf(cb).Elem().Field(f.Index).Set(value.Elem())
+		}
+	}
+
+	if !c.values {
+		return
+	}
+	for _, err := range cb.checkFields() {
+		c.add(lines[err.Field], path+err.Field, "%s", err.Message)
+	}
+}
+
+// merged loads the recipe the way cook does, once per stage, to report
+// fields that are only missing after merging.
+func (c *checker) merged(data []byte) {
+	// values of the wrong type are already reported, check what did decode
+	base, err := parse(data)
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		c.add(0, "", "%s", err.Error())
+		return
+	}
+	stages := append([]string{""}, base.StageNames()...)
+	for _, stage := range stages {
+		cb := base
+		prefix := ""
+		if stage != "" {
+			prefix = "stage " + stage + ": "
+			if cb, err = base.Overlay(stage); err != nil {
+				if !errors.As(err, &typeErr) {
+					c.add(c.stageLines[stage], "stages."+stage, "%s", err.Error())
+				}
+				continue
+			}
+		} else {
+			copied := *base
+			cb = &copied
+		}
+		if err := cb.Prepare(); err != nil {
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				if c.reported(ve.Message) {
+					continue
+				}
+				c.add(c.stageLines[stage], ve.Field, "%s%s", prefix, ve.Message)
+			} else {
+				c.add(c.stageLines[stage], "", "%s%s", prefix, err.Error())
+			}
+		}
+	}
+}
+
+// reported returns whether a problem with the message was already found on
+// a line of its own.
+func (c *checker) reported(message string) bool {
+	for _, p := range c.problems {
+		if p.Line > 0 && strings.HasSuffix(message, p.Message) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.Map:
+		return "a mapping of names to strings"
+	case reflect.Slice:
+		return "a list of strings"
+	}
+	return t.String()
+}
+
+// suggestField returns the known field closest to a misspelt key.
+func suggestField(key string) string {
+	best, bestDistance := "", 3
+	for known := range cookbookFields {
+		if d := editDistance(key, known); d < bestDistance || (d == bestDistance && best != "" && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance counts the insertions, deletions, substitutions and
+// transpositions needed to turn a into b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// schemaConstraints adds the limits enforced by checkFields to the schema.
+var schemaConstraints = map[string]map[string]interface{}{
+	"arn":                      {"pattern": withReference(roleARNPattern)},
+	"runtime":                  {"enum": KnownRuntimes},
+	"memory":                   {"minimum": 128, "maximum": 10240},
+	"timeout":                  {"minimum": 1, "maximum": 900},
+	"ephemeral_storage":        {"minimum": 512, "maximum": 10240},
+	"builder":                  {"enum": []string{"container", "pip"}},
+	"engine":                   {"enum": []string{"docker", "podman", "nerdctl"}},
+	"dependencies":             {"enum": DependencySources},
+	"alias":                    {"pattern": withReference(aliasPattern)},
+	"source":                   {"type": []string{"string", "array"}},
+	"architectures":            {"maxItems": 1, "items": map[string]interface{}{"enum": []string{"x86_64", "arm64"}}},
+	"compatible_runtimes":      {"maxItems": 15, "items": map[string]interface{}{"enum": KnownRuntimes}},
+	"compatible_architectures": {"maxItems": 2, "items": map[string]interface{}{"enum": []string{"x86_64", "arm64"}}},
+}
+
+// withReference returns a schema pattern matching p or a value holding a
+// reference, which is only checked once resolved.
+func withReference(p *regexp.Regexp) string {
+	return p.String() + "|" + referencePattern.String()
+}
+
+// Schema returns a JSON Schema describing recipe files, for editor
+// integration.
+func Schema() ([]byte, error) {
+	props := map[string]interface{}{}
+	for key, f := range cookbookFields {
+		if key == "stages" || key == "functions" {
+			continue
+		}
+		prop := map[string]interface{}{}
+		switch f.Type.Kind() {
+		case reflect.String:
+			prop["type"] = "string"
+		case reflect.Int, reflect.Int64:
+			prop["type"] = "integer"
+		case reflect.Map:
+			prop["type"] = "object"
+			prop["additionalProperties"] = map[string]interface{}{"type": "string"}
+		case reflect.Slice:
+			prop["type"] = "array"
+			prop["items"] = map[string]interface{}{"type": "string"}
+		}
+		for k, v := range schemaConstraints[key] {
+			prop[k] = v
+		}
+		props[key] = prop
+	}
+
+	withKeys := func(extra map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		for k, v := range props {
+			m[k] = v
+		}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+	functions := map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"$ref": "#/definitions/function"},
+	}
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "ChefCLI recipe",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": withKeys(map[string]interface{}{
+			"stages": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"$ref": "#/definitions/stage"},
+			},
+			"functions": functions,
+		}),
+		"definitions": map[string]interface{}{
+			"stage": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           withKeys(map[string]interface{}{"functions": functions}),
+			},
+			"function": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           withKeys(nil),
+			},
+		},
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,125 @@
+package recipe
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		recipe   string
+		problems []string
+	}{
+		{
+			name:   "valid recipe",
+			recipe: stagedRecipe,
+		},
+		{
+			name:     "syntax error",
+			recipe:   "function: orders\nhandler: [handler\n",
+			problems: []string{"line 1: yaml:"},
+		},
+		{
+			name:     "typo and missing handler",
+			recipe:   "function: orders\nhanlder: handler\n",
+			problems: []string{"handler: There is no Handler name.", `line 2: hanlder: Unknown field "hanlder". Did you mean "handler"?`},
+		},
+		{
+			name:     "internal field",
+			recipe:   "function: orders\nhandler: handler\nawscreds: default\n",
+			problems: []string{`line 3: awscreds: Unknown field "awscreds".`},
+		},
+		{
+			name:     "duplicate field",
+			recipe:   "function: orders\nhandler: handler\nfunction: invoices\n",
+			problems: []string{"line 3: function: Duplicate field, first set on line 1."},
+		},
+		{
+			name:   "every problem at once",
+			recipe: "function: orders\nhandler: handler\nmemory: 64\ntimeout: soon\nruntime: python2.7\n",
+			problems: []string{
+				"line 3: memory: Memory must be between 128 and 10240 MB, got 64.",
+				"line 4: timeout: Expected a whole number.",
+				`line 5: runtime: Unknown runtime "python2.7".`,
+			},
+		},
+		{
+			name:     "stage problems",
+			recipe:   "function: orders\nhandler: handler\nstages:\n  prod:\n    functions: {}\n    alias: \"42\"\n",
+			problems: []string{"line 5: stages.prod.functions: Expected a list of functions.", `line 6: stages.prod.alias: Invalid alias "42".`},
+		},
+		{
+			name:     "missing after merging",
+			recipe:   "handler: handler\nfunctions:\n  - memory: 256\n",
+			problems: []string{"functions[0].function: functions[0]: There is no Function name."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := Check([]byte(tt.recipe))
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("Check() = %v, want %d problems", problems, len(tt.problems))
+			}
+			for i, want := range tt.problems {
+				if got := problems[i].String(); !strings.HasPrefix(got, want) {
+					t.Errorf("Check() problem %d = %q, want it to start with %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestField(t *testing.T) {
+	tests := map[string]string{
+		"hanlder":  "handler",
+		"fucntion": "function",
+		"memroy":   "memory",
+		"colour":   "",
+	}
+	for key, want := range tests {
+		if got := suggestField(key); got != want {
+			t.Errorf("suggestField(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Pattern string `json:"pattern"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, internal := range []string{"awscreds", "tfplan", "recipe"} {
+		if _, ok := schema.Properties[internal]; ok {
+			t.Errorf("Schema() has the internal field %s", internal)
+		}
+	}
+
+	tests := []struct {
+		field, value string
+		ok           bool
+	}{
+		{"arn", "arn:aws:iam::123456789012:role/lambda", true},
+		{"arn", "${ssm:/roles/lambda}", true},
+		{"arn", "lambda", false},
+		{"alias", "live", true},
+		{"alias", "${env:ALIAS}", true},
+		{"alias", "not an alias", false},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile(schema.Properties[tt.field].Pattern)
+		if got := re.MatchString(tt.value); got != tt.ok {
+			t.Errorf("Schema() %s pattern matches %q = %v, want %v", tt.field, tt.value, got, tt.ok)
+		}
+	}
+}